@@ -0,0 +1,521 @@
+package extnetip
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrInvalidPrefix is returned for invalid prefixes or prefix lengths.
+	ErrInvalidPrefix = errors.New("extnetip: invalid prefix")
+
+	// ErrNoSpace is returned if no free prefix or address is available.
+	ErrNoSpace = errors.New("extnetip: no free space")
+
+	// ErrNotInPool is returned if a prefix is not covered by the parent prefixes.
+	ErrNotInPool = errors.New("extnetip: prefix not in pool")
+
+	// ErrNotAllocated is returned if a prefix to be released is not allocated.
+	ErrNotAllocated = errors.New("extnetip: prefix not allocated")
+)
+
+// AllocStrategy defines how an [Allocator] selects a free prefix.
+type AllocStrategy uint8
+
+const (
+	// FirstFit allocates the lowest free prefix of the requested size.
+	FirstFit AllocStrategy = iota
+
+	// BestFit allocates from the smallest free block large enough for
+	// the requested size, this keeps larger blocks available.
+	BestFit
+
+	// SparseLeftmost assigns the subnet bits between the parent and the
+	// requested prefix length from the left, RFC 3531. The allocations are
+	// spread by bisection over the parent, each one keeps the maximum room
+	// to grow by shorter prefix lengths, see [Allocator.Grow].
+	SparseLeftmost
+
+	// SparseRightmost assigns the subnet bits from the right, RFC 3531,
+	// this is a dense allocation in ascending order, leaving room at
+	// the end of the parent.
+	SparseRightmost
+
+	// SparseCentermost assigns the subnet bits starting from the center
+	// of the subnet field, alternating towards the left and right, RFC 3531.
+	SparseCentermost
+)
+
+// Allocator allocates prefixes of a given size from a pool of parent prefixes.
+//
+// The free space is computed with [Gaps] from the allocated prefixes,
+// all calculations are done in uint128 space.
+//
+// An Allocator is safe for concurrent use.
+type Allocator struct {
+	mu        sync.Mutex
+	strategy  AllocStrategy
+	parents   []netip.Prefix
+	allocated Table[struct{}]
+	persister Persister
+}
+
+// AllocStats are the utilization statistics of an [Allocator].
+type AllocStats struct {
+	// Allocations is the number of allocated prefixes.
+	Allocations int
+
+	// FreeBlocks is the number of free prefixes, see [Gaps].
+	FreeBlocks int
+
+	// LargestFree is the largest free prefix, the zero value if the pool is full.
+	LargestFree netip.Prefix
+
+	// Utilization4 and Utilization6 are the fractions of allocated addresses
+	// in the IPv4 and IPv6 parents, the IP versions are not mixed in one
+	// ratio. It is zero if there is no parent of the IP version.
+	Utilization4 float64
+	Utilization6 float64
+}
+
+// NewAllocator returns an allocator for the pool of parent prefixes.
+//
+// The parents are stored in canonical form and must not overlap.
+func NewAllocator(strategy AllocStrategy, parents ...netip.Prefix) (*Allocator, error) {
+	pfxs := make([]netip.Prefix, 0, len(parents))
+	for _, p := range parents {
+		if !p.IsValid() {
+			return nil, fmt.Errorf("%w: parent %s", ErrInvalidPrefix, p)
+		}
+		pfxs = append(pfxs, p.Masked())
+	}
+	SortPrefixes(pfxs)
+
+	for i := 1; i < len(pfxs); i++ {
+		if pfxs[i-1].Overlaps(pfxs[i]) {
+			return nil, fmt.Errorf("%w: parent %s overlaps %s", ErrOverlap, pfxs[i-1], pfxs[i])
+		}
+	}
+
+	return &Allocator{strategy: strategy, parents: pfxs}, nil
+}
+
+// Allocate returns a free prefix with the prefix length bits and marks it
+// as allocated. The parents are tried in CIDR order, IPv4 before IPv6,
+// bits is relative to the IP version of each parent.
+//
+// It returns an error wrapping [ErrNoSpace] if no free prefix is available.
+func (a *Allocator) Allocate(bits int) (netip.Prefix, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var best netip.Prefix
+	for _, parent := range a.parents {
+		if bits < parent.Bits() || bits > parent.Addr().BitLen() {
+			continue
+		}
+
+		if a.strategy >= SparseLeftmost {
+			if pfx, ok := a.findSparse(parent, bits); ok {
+				if err := a.insert(pfx); err != nil {
+					return netip.Prefix{}, err
+				}
+				return pfx, nil
+			}
+			continue
+		}
+
+		gap := a.findFree(parent, bits)
+		if !gap.IsValid() {
+			continue
+		}
+
+		if a.strategy == FirstFit {
+			best = gap
+			break
+		}
+
+		// best fit: the smallest free block over all parents,
+		// compared by size across the IP versions
+		if !best.IsValid() || hostLen(gap) < hostLen(best) {
+			best = gap
+		}
+	}
+
+	if !best.IsValid() {
+		return netip.Prefix{}, fmt.Errorf("%w: /%d", ErrNoSpace, bits)
+	}
+
+	pfx := netip.PrefixFrom(best.Addr(), bits)
+	if err := a.insert(pfx); err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return pfx, nil
+}
+
+// insert persists the allocation of pfx and marks it as allocated.
+func (a *Allocator) insert(pfx netip.Prefix) error {
+	if err := a.persist(Record{Op: RecordAllocate, Prefix: pfx}); err != nil {
+		return err
+	}
+	a.allocated.Insert(pfx, struct{}{})
+	return nil
+}
+
+// persist passes the record to the persister, if any.
+func (a *Allocator) persist(r Record) error {
+	if a.persister == nil {
+		return nil
+	}
+	return a.persister.Persist(r)
+}
+
+// SetPersister sets the persister for all following state changes,
+// nil disables the persistence, see [Journal].
+//
+// A change is rejected with the error of the persister if it can't be persisted.
+func (a *Allocator) SetPersister(p Persister) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.persister = p
+}
+
+// Replay applies the allocation records, e.g. recovered from a [Journal],
+// other records are ignored. The records are not persisted again.
+//
+// The records are validated like the corresponding methods, it returns an
+// error wrapping [ErrNotInPool], [ErrOverlap] or [ErrNotAllocated] for the
+// first invalid record, the preceding records remain applied.
+func (a *Allocator) Replay(recs iter.Seq[Record]) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for r := range recs {
+		if r.Op < RecordAllocate || r.Op > RecordGrow {
+			continue
+		}
+		if !r.Prefix.IsValid() {
+			return fmt.Errorf("%w: %s %s", ErrInvalidPrefix, r.Op, r.Prefix)
+		}
+
+		pfx := r.Prefix.Masked()
+		if !a.inPool(pfx) {
+			return fmt.Errorf("%w: %s %s", ErrNotInPool, r.Op, pfx)
+		}
+
+		switch r.Op {
+		case RecordAllocate:
+			if c, ok := conflict(&a.allocated, pfx); ok {
+				return fmt.Errorf("%w: %s overlaps allocated %s", ErrOverlap, pfx, c)
+			}
+			a.allocated.Insert(pfx, struct{}{})
+
+		case RecordRelease:
+			if _, ok := a.allocated.Delete(pfx); !ok {
+				return fmt.Errorf("%w: %s", ErrNotAllocated, pfx)
+			}
+
+		case RecordGrow:
+			sub, err := grownFrom(&a.allocated, pfx)
+			if err != nil {
+				return err
+			}
+			a.allocated.Delete(sub)
+			a.allocated.Insert(pfx, struct{}{})
+		}
+	}
+	return nil
+}
+
+// findFree returns the free block in parent to allocate a prefix with bits from,
+// according to the strategy. The zero value is returned if there is none.
+func (a *Allocator) findFree(parent netip.Prefix, bits int) (free netip.Prefix) {
+	for gap := range Gaps(parent, a.allocatedIn(parent)) {
+		if gap.Bits() > bits {
+			continue
+		}
+
+		if a.strategy == FirstFit {
+			return gap
+		}
+
+		if !free.IsValid() || gap.Bits() > free.Bits() {
+			free = gap
+		}
+	}
+	return
+}
+
+// findSparse returns the first free prefix with bits in parent, according
+// to the RFC 3531 bit assignment order of the sparse strategy.
+//
+// The candidates are enumerated by a counter k, the bits of k are assigned to
+// the subnet field between parent.Bits() and bits in the strategy dependent
+// order. Instead of probing each candidate, the free blocks are taken from
+// [Gaps]: the first candidate in a free block is its lowest subnet, with all
+// unfixed field bits zero. The result is the block with the smallest counter
+// value, the costs are linear in the number of free blocks.
+func (a *Allocator) findSparse(parent netip.Prefix, bits int) (netip.Prefix, bool) {
+	_, parentBits, _ := tableKey(parent)
+
+	n := bits - parent.Bits()        // width of the subnet field
+	hostBits := 128 - parentBits - n // bits right of the subnet field
+
+	// field bit positions, counted from the rightmost bit of the field,
+	// in assignment order for the counter bits
+	order := make([]int, 0, n)
+	switch a.strategy {
+	case SparseLeftmost:
+		for i := range n {
+			order = append(order, n-1-i)
+		}
+	case SparseRightmost:
+		for i := range n {
+			order = append(order, i)
+		}
+	case SparseCentermost:
+		mid := (n - 1) / 2 // counted from the left
+		for i := 0; len(order) < n; i++ {
+			if mid-i >= 0 {
+				order = append(order, n-1-(mid-i))
+			}
+			if i > 0 && mid+i < n {
+				order = append(order, n-1-(mid+i))
+			}
+		}
+	}
+
+	var best netip.Prefix
+	var bestK uint128
+
+	for gap := range Gaps(parent, a.allocatedIn(parent)) {
+		if gap.Bits() > bits {
+			continue
+		}
+
+		// the counter value of the lowest subnet in gap
+		id := unwrap(gap.Addr()).ip.rsh(hostBits)
+		var k uint128
+		for i, pos := range order {
+			if id.rsh(pos).lo&1 == 1 {
+				k = k.or(uint128{0, 1}.lsh(i))
+			}
+		}
+
+		if !best.IsValid() || k.compare(bestK) < 0 {
+			best, bestK = gap, k
+		}
+	}
+
+	if !best.IsValid() {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(best.Addr(), bits), true
+}
+
+// allocatedIn returns an iterator over the allocated prefixes in parent.
+func (a *Allocator) allocatedIn(parent netip.Prefix) iter.Seq[netip.Prefix] {
+	return func(yield func(netip.Prefix) bool) {
+		for pfx := range a.allocated.Subnets(parent) {
+			if !yield(pfx) {
+				return
+			}
+		}
+	}
+}
+
+// AllocateSpecific marks the prefix pfx as allocated.
+//
+// It returns an error wrapping [ErrNotInPool] if pfx is not covered by a
+// parent, or wrapping [ErrOverlap] if pfx overlaps an allocated prefix.
+func (a *Allocator) AllocateSpecific(pfx netip.Prefix) error {
+	if !pfx.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidPrefix, pfx)
+	}
+	pfx = pfx.Masked()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.inPool(pfx) {
+		return fmt.Errorf("%w: %s", ErrNotInPool, pfx)
+	}
+
+	if c, ok := conflict(&a.allocated, pfx); ok {
+		return fmt.Errorf("%w: %s overlaps allocated %s", ErrOverlap, pfx, c)
+	}
+
+	return a.insert(pfx)
+}
+
+// inPool reports whether pfx is covered by a parent.
+func (a *Allocator) inPool(pfx netip.Prefix) bool {
+	for _, parent := range a.parents {
+		if parent.Bits() <= pfx.Bits() && parent.Contains(pfx.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// conflict returns a prefix in t overlapping pfx.
+func conflict(t *Table[struct{}], pfx netip.Prefix) (netip.Prefix, bool) {
+	for super := range t.Supernets(pfx) {
+		return super, true
+	}
+	for sub := range t.Subnets(pfx) {
+		return sub, true
+	}
+	return netip.Prefix{}, false
+}
+
+// grownFrom returns the single prefix in t covered by super, which
+// is replaced by super in a grow operation.
+func grownFrom(t *Table[struct{}], super netip.Prefix) (netip.Prefix, error) {
+	var pfxs []netip.Prefix
+	for sub := range t.Subnets(super) {
+		pfxs = append(pfxs, sub)
+	}
+	for sup := range t.Supernets(super) {
+		if sup != super {
+			return netip.Prefix{}, fmt.Errorf("%w: %s overlaps allocated %s", ErrOverlap, super, sup)
+		}
+	}
+
+	switch len(pfxs) {
+	case 0:
+		return netip.Prefix{}, fmt.Errorf("%w: %s", ErrNotAllocated, super)
+	case 1:
+		return pfxs[0], nil
+	}
+	return netip.Prefix{}, fmt.Errorf("%w: %s overlaps allocated %s", ErrOverlap, super, pfxs[1])
+}
+
+// Release marks the allocated prefix pfx as free.
+//
+// It returns an error wrapping [ErrNotAllocated] if pfx is not allocated.
+func (a *Allocator) Release(pfx netip.Prefix) error {
+	if !pfx.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidPrefix, pfx)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.allocated.Get(pfx); !ok {
+		return fmt.Errorf("%w: %s", ErrNotAllocated, pfx)
+	}
+
+	if err := a.persist(Record{Op: RecordRelease, Prefix: pfx.Masked()}); err != nil {
+		return err
+	}
+
+	a.allocated.Delete(pfx)
+	return nil
+}
+
+// Grow replaces the allocated prefix pfx in place by its supernet with the
+// shorter prefix length bits, the addresses in pfx are not renumbered.
+//
+// It returns an error wrapping [ErrNotAllocated] if pfx is not allocated,
+// [ErrNotInPool] if the supernet is not covered by a parent or [ErrOverlap]
+// if the supernet overlaps other allocated prefixes.
+func (a *Allocator) Grow(pfx netip.Prefix, bits int) (netip.Prefix, error) {
+	if !pfx.IsValid() || bits < 0 || bits > pfx.Bits() {
+		return netip.Prefix{}, fmt.Errorf("%w: %s to /%d", ErrInvalidPrefix, pfx, bits)
+	}
+	pfx = pfx.Masked()
+	super := netip.PrefixFrom(pfx.Addr(), bits).Masked()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.allocated.Get(pfx); !ok {
+		return netip.Prefix{}, fmt.Errorf("%w: %s", ErrNotAllocated, pfx)
+	}
+
+	if !a.inPool(super) {
+		return netip.Prefix{}, fmt.Errorf("%w: %s", ErrNotInPool, super)
+	}
+
+	a.allocated.Delete(pfx)
+	if c, ok := conflict(&a.allocated, super); ok {
+		a.allocated.Insert(pfx, struct{}{})
+		return netip.Prefix{}, fmt.Errorf("%w: %s overlaps allocated %s", ErrOverlap, super, c)
+	}
+
+	if err := a.persist(Record{Op: RecordGrow, Prefix: super}); err != nil {
+		a.allocated.Insert(pfx, struct{}{})
+		return netip.Prefix{}, err
+	}
+
+	a.allocated.Insert(super, struct{}{})
+	return super, nil
+}
+
+// All returns an iterator over a snapshot of the allocated prefixes in CIDR order.
+func (a *Allocator) All() iter.Seq[netip.Prefix] {
+	a.mu.Lock()
+	pfxs := make([]netip.Prefix, 0, a.allocated.Len())
+	for pfx := range a.allocated.All2() {
+		pfxs = append(pfxs, pfx)
+	}
+	a.mu.Unlock()
+
+	return slices.Values(pfxs)
+}
+
+// Stats returns the utilization statistics of the pool.
+func (a *Allocator) Stats() (stats AllocStats) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// address counts by IP version, index 0 for IPv4 and 1 for IPv6
+	var total, used [2]float64
+	for _, parent := range a.parents {
+		v := 1
+		if parent.Addr().Is4() {
+			v = 0
+		}
+		total[v] += prefixSize(parent)
+
+		for pfx := range a.allocated.Subnets(parent) {
+			used[v] += prefixSize(pfx)
+			stats.Allocations++
+		}
+
+		for gap := range Gaps(parent, a.allocatedIn(parent)) {
+			stats.FreeBlocks++
+			if !stats.LargestFree.IsValid() || hostLen(gap) > hostLen(stats.LargestFree) {
+				stats.LargestFree = gap
+			}
+		}
+	}
+
+	if total[0] > 0 {
+		stats.Utilization4 = used[0] / total[0]
+	}
+	if total[1] > 0 {
+		stats.Utilization6 = used[1] / total[1]
+	}
+	return
+}
+
+// prefixSize returns the number of addresses in pfx as float64,
+// the number of IPv6 addresses can exceed the uint64 range.
+func prefixSize(pfx netip.Prefix) float64 {
+	return math.Ldexp(1, hostLen(pfx))
+}
+
+// hostLen returns the number of host bits of pfx, the size of
+// prefixes of different IP versions is compared by it.
+func hostLen(pfx netip.Prefix) int {
+	return pfx.Addr().BitLen() - pfx.Bits()
+}
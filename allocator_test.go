@@ -0,0 +1,357 @@
+package extnetip_test
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestNewAllocator(t *testing.T) {
+	t.Parallel()
+	if _, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/8"), mpp("10.1.0.0/16")); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("NewAllocator, overlapping parents, got: %v, want: %v", err, extnetip.ErrOverlap)
+	}
+	if _, err := extnetip.NewAllocator(extnetip.FirstFit, netip.Prefix{}); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("NewAllocator, invalid parent, got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+}
+
+func TestAllocatorFirstFit(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.AllocateSpecific(mpp("10.0.0.64/26")); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []netip.Prefix
+	for _, bits := range []int{26, 27, 26, 28} {
+		pfx, err := a.Allocate(bits)
+		if err != nil {
+			t.Fatalf("Allocate(%d), unexpected error: %v", bits, err)
+		}
+		got = append(got, pfx)
+	}
+
+	want := pfxSlice("10.0.0.0/26", "10.0.0.128/27", "10.0.0.192/26", "10.0.0.160/28")
+	if !slices.Equal(got, want) {
+		t.Errorf("Allocate, got: %v, want: %v", got, want)
+	}
+
+	if _, err := a.Allocate(27); !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("Allocate(27), got: %v, want: %v", err, extnetip.ErrNoSpace)
+	}
+	if _, err := a.Allocate(23); !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("Allocate(23), got: %v, want: %v", err, extnetip.ErrNoSpace)
+	}
+
+	if err := a.Release(mpp("10.0.0.0/26")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Release(mpp("10.0.0.0/26")); !errors.Is(err, extnetip.ErrNotAllocated) {
+		t.Errorf("Release twice, got: %v, want: %v", err, extnetip.ErrNotAllocated)
+	}
+
+	if pfx, err := a.Allocate(27); err != nil || pfx != mpp("10.0.0.0/27") {
+		t.Errorf("Allocate(27) after release, got: %s, %v, want: 10.0.0.0/27", pfx, err)
+	}
+}
+
+func TestAllocatorBestFit(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.BestFit, mpp("10.0.0.0/24"), mpp("10.0.1.0/28"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// free: 10.0.0.0/26, 10.0.0.128/25 and 10.0.1.0/28
+	if err := a.AllocateSpecific(mpp("10.0.0.64/26")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		bits int
+		want netip.Prefix
+	}{
+		{29, mpp("10.0.1.0/29")},   // smallest free block
+		{27, mpp("10.0.0.0/27")},   // the /26 fits best
+		{26, mpp("10.0.0.128/26")}, // only the /25 fits
+	}
+
+	for _, tt := range tests {
+		got, err := a.Allocate(tt.bits)
+		if err != nil || got != tt.want {
+			t.Errorf("Allocate(%d), got: %s, %v, want: %s", tt.bits, got, err, tt.want)
+		}
+	}
+}
+
+func TestAllocatorBestFitMixed(t *testing.T) {
+	t.Parallel()
+	// the IPv6 /26 has the shorter prefix length but is the larger block
+	a, err := extnetip.NewAllocator(extnetip.BestFit, mpp("10.0.0.0/24"), mpp("2001:dc0::/26"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := a.Allocate(28); err != nil || got != mpp("10.0.0.0/28") {
+		t.Errorf("Allocate(28), got: %s, %v, want: 10.0.0.0/28", got, err)
+	}
+}
+
+func TestAllocatorSpecific(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/16"), mpp("2001:db8::/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pfx  netip.Prefix
+		want error
+	}{
+		{mpp("10.0.1.0/24"), nil},
+		{mpp("10.0.1.128/25"), extnetip.ErrOverlap},
+		{mpp("10.0.0.0/23"), extnetip.ErrOverlap},
+		{mpp("10.1.0.0/24"), extnetip.ErrNotInPool},
+		{mpp("10.0.0.0/8"), extnetip.ErrNotInPool},
+		{netip.Prefix{}, extnetip.ErrInvalidPrefix},
+		{mpp("2001:db8:1::/48"), nil},
+	}
+
+	for _, tt := range tests {
+		err := a.AllocateSpecific(tt.pfx)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("AllocateSpecific(%s), got: %v, want: %v", tt.pfx, err, tt.want)
+		}
+	}
+
+	if got, want := slices.Collect(a.All()), pfxSlice("10.0.1.0/24", "2001:db8:1::/48"); !slices.Equal(got, want) {
+		t.Errorf("All(), got: %v, want: %v", got, want)
+	}
+}
+
+func TestAllocatorStats(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bits := range []int{25, 26} {
+		if _, err := a.Allocate(bits); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := a.Stats()
+	want := extnetip.AllocStats{
+		Allocations:  2,
+		FreeBlocks:   1,
+		LargestFree:  mpp("10.0.0.192/26"),
+		Utilization4: 0.75,
+	}
+	if got != want {
+		t.Errorf("Stats(), got: %+v, want: %+v", got, want)
+	}
+}
+
+func TestAllocatorStatsMixed(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/8"), mpp("2001:db8::/64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := a.Stats().LargestFree, mpp("2001:db8::/64"); got != want {
+		t.Errorf("Stats().LargestFree, got: %s, want: %s", got, want)
+	}
+
+	// a full IPv4 parent isn't diluted by the IPv6 address space
+	if err := a.AllocateSpecific(mpp("10.0.0.0/8")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AllocateSpecific(mpp("2001:db8::/65")); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Stats(); got.Utilization4 != 1 || got.Utilization6 != 0.5 {
+		t.Errorf("Stats(), got: %v %v, want: 1 0.5", got.Utilization4, got.Utilization6)
+	}
+}
+
+func TestAllocatorConcurrent(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan netip.Prefix, 256)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 32 {
+				pfx, err := a.Allocate(24)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results <- pfx
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := map[netip.Prefix]bool{}
+	for pfx := range results {
+		if seen[pfx] {
+			t.Fatalf("Allocate, duplicate prefix %s", pfx)
+		}
+		seen[pfx] = true
+	}
+
+	if _, err := a.Allocate(24); !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("Allocate, pool should be exhausted, got: %v", err)
+	}
+}
+
+func TestAllocatorSparse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		strategy extnetip.AllocStrategy
+		want     []netip.Prefix
+	}{
+		{extnetip.SparseLeftmost, pfxSlice(
+			"2001:db8::/56", "2001:db8:0:8000::/56", "2001:db8:0:4000::/56", "2001:db8:0:c000::/56", "2001:db8:0:2000::/56",
+		)},
+		{extnetip.SparseRightmost, pfxSlice(
+			"2001:db8::/56", "2001:db8:0:100::/56", "2001:db8:0:200::/56", "2001:db8:0:300::/56", "2001:db8:0:400::/56",
+		)},
+		{extnetip.SparseCentermost, pfxSlice(
+			"2001:db8::/56", "2001:db8:0:1000::/56", "2001:db8:0:2000::/56", "2001:db8:0:3000::/56", "2001:db8:0:800::/56",
+		)},
+	}
+
+	for _, tt := range tests {
+		a, err := extnetip.NewAllocator(tt.strategy, mpp("2001:db8::/48"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []netip.Prefix
+		for range len(tt.want) {
+			pfx, err := a.Allocate(56)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, pfx)
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("strategy %d, Allocate(56), got: %v, want: %v", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestAllocatorSparseExhaust(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.SparseLeftmost, mpp("10.0.0.0/24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deterministic bisection order: .0, .128, .64, .192, ...
+	var got []netip.Prefix
+	for range 4 {
+		pfx, err := a.Allocate(26)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, pfx)
+	}
+	if want := pfxSlice("10.0.0.0/26", "10.0.0.128/26", "10.0.0.64/26", "10.0.0.192/26"); !slices.Equal(got, want) {
+		t.Errorf("Allocate(26), got: %v, want: %v", got, want)
+	}
+
+	if _, err := a.Allocate(26); !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("Allocate(26), got: %v, want: %v", err, extnetip.ErrNoSpace)
+	}
+}
+
+func TestAllocatorSparseLarge(t *testing.T) {
+	t.Parallel()
+	parent := mpp("2001:db8::/32")
+	last := mpp("2001:db8:ffff:ffff::/64") // the last candidate in leftmost order
+
+	for _, strategy := range []extnetip.AllocStrategy{extnetip.SparseLeftmost, extnetip.SparseRightmost, extnetip.SparseCentermost} {
+		a, err := extnetip.NewAllocator(strategy, parent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// allocate all but the last /64, 2^32 candidates are not probed one by one
+		for pfx := range extnetip.Gaps(parent, slices.Values([]netip.Prefix{last})) {
+			if err := a.AllocateSpecific(pfx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got, err := a.Allocate(64); err != nil || got != last {
+			t.Errorf("strategy %d, Allocate(64), got: %s, %v, want: %s", strategy, got, err, last)
+		}
+		if _, err := a.Allocate(64); !errors.Is(err, extnetip.ErrNoSpace) {
+			t.Errorf("strategy %d, Allocate(64), got: %v, want: %v", strategy, err, extnetip.ErrNoSpace)
+		}
+	}
+}
+
+func TestAllocatorGrow(t *testing.T) {
+	t.Parallel()
+	a, err := extnetip.NewAllocator(extnetip.SparseLeftmost, mpp("2001:db8::/48"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pfxs []netip.Prefix
+	for range 4 {
+		pfx, err := a.Allocate(56)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pfxs = append(pfxs, pfx)
+	}
+
+	// the sparse allocation leaves room to grow each prefix up to a /50
+	got, err := a.Grow(pfxs[1], 52)
+	if err != nil || got != mpp("2001:db8:0:8000::/52") {
+		t.Errorf("Grow(%s, 52), got: %s, %v, want: 2001:db8:0:8000::/52", pfxs[1], got, err)
+	}
+
+	if _, err := a.Grow(pfxs[0], 49); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("Grow(%s, 49), got: %v, want: %v", pfxs[0], err, extnetip.ErrOverlap)
+	}
+	if _, err := a.Grow(pfxs[0], 47); !errors.Is(err, extnetip.ErrNotInPool) {
+		t.Errorf("Grow(%s, 47), got: %v, want: %v", pfxs[0], err, extnetip.ErrNotInPool)
+	}
+	if _, err := a.Grow(pfxs[1], 51); !errors.Is(err, extnetip.ErrNotAllocated) {
+		t.Errorf("Grow(%s, 51), got: %v, want: %v", pfxs[1], err, extnetip.ErrNotAllocated)
+	}
+	if _, err := a.Grow(pfxs[0], 57); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("Grow(%s, 57), got: %v, want: %v", pfxs[0], err, extnetip.ErrInvalidPrefix)
+	}
+
+	// the failed grow must not lose the allocation
+	if _, err := a.Grow(pfxs[0], 50); err != nil {
+		t.Errorf("Grow(%s, 50), unexpected error: %v", pfxs[0], err)
+	}
+}
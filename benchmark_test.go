@@ -1,6 +1,12 @@
 package extnetip
 
-import "testing"
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"strconv"
+	"testing"
+)
 
 func BenchmarkConversion(b *testing.B) {
 	v4 := mustAddr("0.0.0.0")
@@ -86,3 +92,86 @@ func BenchmarkCommonPrefix(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkComparePrefix(b *testing.B) {
+	v4Pfx1 := mustPfx("10.1.2.0/13")
+	v4Pfx2 := mustPfx("10.1.2.0/30")
+
+	v6Pfx1 := mustPfx("2001:db8:7ff:beef::/56")
+	v6Pfx2 := mustPfx("2001:db8:7fe:affe::/64")
+
+	b.Run("v4", func(b *testing.B) {
+		for b.Loop() {
+			ComparePrefix(v4Pfx1, v4Pfx2)
+		}
+	})
+
+	b.Run("v6", func(b *testing.B) {
+		for b.Loop() {
+			ComparePrefix(v6Pfx1, v6Pfx2)
+		}
+	})
+}
+
+func benchAddrs(n int) []netip.Addr {
+	prng := rand.New(rand.NewPCG(42, 42))
+	addrs := make([]netip.Addr, n)
+	for i := range addrs {
+		if i%2 == 0 {
+			addrs[i] = netip.AddrFrom4([4]byte{byte(prng.Uint32()), byte(prng.Uint32()), byte(prng.Uint32()), byte(prng.Uint32())})
+			continue
+		}
+		var a16 [16]byte
+		for j := range a16 {
+			a16[j] = byte(prng.Uint32())
+		}
+		addrs[i] = netip.AddrFrom16(a16)
+	}
+	return addrs
+}
+
+func BenchmarkSortAddrs(b *testing.B) {
+	for _, n := range []int{1_000, 100_000} {
+		addrs := benchAddrs(n)
+		buf := make([]netip.Addr, n)
+
+		b.Run("SortFunc/"+strconv.Itoa(n), func(b *testing.B) {
+			for b.Loop() {
+				copy(buf, addrs)
+				slices.SortFunc(buf, netip.Addr.Compare)
+			}
+		})
+
+		b.Run("SortAddrs/"+strconv.Itoa(n), func(b *testing.B) {
+			for b.Loop() {
+				copy(buf, addrs)
+				SortAddrs(buf)
+			}
+		})
+	}
+}
+
+func BenchmarkSortPrefixes(b *testing.B) {
+	for _, n := range []int{1_000, 100_000} {
+		prng := rand.New(rand.NewPCG(42, 42))
+		pfxs := make([]netip.Prefix, n)
+		for i, a := range benchAddrs(n) {
+			pfxs[i] = netip.PrefixFrom(a, prng.IntN(a.BitLen()+1)).Masked()
+		}
+		buf := make([]netip.Prefix, n)
+
+		b.Run("SortFunc/"+strconv.Itoa(n), func(b *testing.B) {
+			for b.Loop() {
+				copy(buf, pfxs)
+				slices.SortFunc(buf, ComparePrefix)
+			}
+		})
+
+		b.Run("SortPrefixes/"+strconv.Itoa(n), func(b *testing.B) {
+			for b.Loop() {
+				copy(buf, pfxs)
+				SortPrefixes(buf)
+			}
+		})
+	}
+}
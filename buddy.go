@@ -0,0 +1,298 @@
+package extnetip
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+// BuddyAllocator is a buddy-system allocator over a parent prefix.
+//
+// Free blocks are split in halves on allocation and released blocks are
+// merged with their free sibling, the buddy, back into the larger block.
+// Each two buddies form an exact prefix one bit shorter, the buddy of a
+// block is found by flipping its last prefix bit.
+//
+// A BuddyAllocator is safe for concurrent use.
+type BuddyAllocator struct {
+	mu     sync.Mutex
+	parent netip.Prefix
+	is4    bool
+
+	// free blocks per prefix length in uint128 space, sorted
+	free [129][]uint128
+
+	allocated map[netip.Prefix]struct{}
+}
+
+// BuddyStats are the utilization and fragmentation statistics
+// of a [BuddyAllocator].
+type BuddyStats struct {
+	// Allocations is the number of allocated prefixes.
+	Allocations int
+
+	// FreeBlocks is the number of free blocks.
+	FreeBlocks int
+
+	// LargestFree is the largest free block, the zero value if the pool is full.
+	LargestFree netip.Prefix
+
+	// Utilization is the fraction of allocated addresses in the parent.
+	Utilization float64
+
+	// Fragmentation is the fraction of free addresses outside the largest
+	// free block, 0 if all free addresses are in a single block.
+	Fragmentation float64
+}
+
+// BuddySnapshot is the state of a [BuddyAllocator], the free blocks are
+// fully determined by the allocated prefixes.
+type BuddySnapshot struct {
+	Parent    netip.Prefix
+	Allocated []netip.Prefix
+}
+
+// NewBuddyAllocator returns a buddy allocator over the parent prefix,
+// the parent is stored in canonical form.
+func NewBuddyAllocator(parent netip.Prefix) (*BuddyAllocator, error) {
+	if !parent.IsValid() {
+		return nil, fmt.Errorf("%w: parent %s", ErrInvalidPrefix, parent)
+	}
+
+	b := &BuddyAllocator{}
+	b.reset(parent.Masked())
+	return b, nil
+}
+
+// reset initializes the allocator with the parent as single free block.
+func (b *BuddyAllocator) reset(parent netip.Prefix) {
+	key, bits, is4 := tableKey(parent)
+
+	b.parent = parent
+	b.is4 = is4
+	b.free = [129][]uint128{}
+	b.free[bits] = []uint128{key}
+	b.allocated = map[netip.Prefix]struct{}{}
+}
+
+// level returns the prefix length in uint128 space.
+func (b *BuddyAllocator) level(bits int) int {
+	if b.is4 {
+		return bits + 96
+	}
+	return bits
+}
+
+// prefix returns the block with key at level as netip.Prefix.
+func (b *BuddyAllocator) prefix(key uint128, level int) netip.Prefix {
+	if b.is4 {
+		level -= 96
+	}
+	return netip.PrefixFrom(wrap(fromUint128(key, b.is4)), level)
+}
+
+// buddy returns the sibling of the block with key at level,
+// both together form the block one bit shorter.
+func buddy(key uint128, level int) uint128 {
+	return key.xor(uint128{0, 1}.lsh(128 - level))
+}
+
+// addFree inserts the block into the sorted free list of the level.
+func (b *BuddyAllocator) addFree(key uint128, level int) {
+	i, _ := slices.BinarySearchFunc(b.free[level], key, uint128.compare)
+	b.free[level] = slices.Insert(b.free[level], i, key)
+}
+
+// removeFree removes the block from the free list of the level,
+// it returns false if the block is not free.
+func (b *BuddyAllocator) removeFree(key uint128, level int) bool {
+	i, ok := slices.BinarySearchFunc(b.free[level], key, uint128.compare)
+	if ok {
+		b.free[level] = slices.Delete(b.free[level], i, i+1)
+	}
+	return ok
+}
+
+// Allocate returns a free prefix with the prefix length bits and marks it
+// as allocated. It takes the lowest block of the smallest non-empty free
+// level that fits and splits it down, the result is the lowest prefix in
+// that block, not necessarily the lowest free prefix in the parent.
+//
+// It returns an error wrapping [ErrNoSpace] if no free prefix is available.
+func (b *BuddyAllocator) Allocate(bits int) (netip.Prefix, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if bits < b.parent.Bits() || bits > b.parent.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("%w: /%d in %s", ErrInvalidPrefix, bits, b.parent)
+	}
+	want := b.level(bits)
+
+	// find the smallest free block large enough
+	level := want
+	for level >= b.level(b.parent.Bits()) && len(b.free[level]) == 0 {
+		level--
+	}
+	if level < b.level(b.parent.Bits()) {
+		return netip.Prefix{}, fmt.Errorf("%w: /%d", ErrNoSpace, bits)
+	}
+
+	key := b.free[level][0]
+	b.free[level] = b.free[level][1:]
+
+	// split down, the upper halves become free
+	for ; level < want; level++ {
+		b.addFree(buddy(key, level+1), level+1)
+	}
+
+	pfx := b.prefix(key, want)
+	b.allocated[pfx] = struct{}{}
+
+	return pfx, nil
+}
+
+// AllocateSpecific marks the prefix pfx as allocated, the free block
+// containing pfx is split down to pfx.
+//
+// It returns an error wrapping [ErrNotInPool] if pfx is not covered by the
+// parent, or wrapping [ErrOverlap] if pfx is not completely free.
+func (b *BuddyAllocator) AllocateSpecific(pfx netip.Prefix) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.allocateSpecific(pfx)
+}
+
+func (b *BuddyAllocator) allocateSpecific(pfx netip.Prefix) error {
+	if !pfx.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidPrefix, pfx)
+	}
+	pfx = pfx.Masked()
+
+	if pfx.Bits() < b.parent.Bits() || !b.parent.Contains(pfx.Addr()) {
+		return fmt.Errorf("%w: %s", ErrNotInPool, pfx)
+	}
+
+	target, want, _ := tableKey(pfx)
+
+	// find the free block containing pfx
+	level := want
+	for ; level >= b.level(b.parent.Bits()); level-- {
+		if b.removeFree(target.and(mask6(level)), level) {
+			break
+		}
+	}
+	if level < b.level(b.parent.Bits()) {
+		return fmt.Errorf("%w: %s is not free", ErrOverlap, pfx)
+	}
+
+	// split down towards the target, the other halves become free
+	for ; level < want; level++ {
+		b.addFree(buddy(target.and(mask6(level+1)), level+1), level+1)
+	}
+
+	b.allocated[pfx] = struct{}{}
+	return nil
+}
+
+// Release marks the allocated prefix pfx as free and merges it with its
+// free buddies into the largest possible free block.
+//
+// It returns an error wrapping [ErrNotAllocated] if pfx is not allocated.
+func (b *BuddyAllocator) Release(pfx netip.Prefix) error {
+	if !pfx.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidPrefix, pfx)
+	}
+	pfx = pfx.Masked()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.allocated[pfx]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotAllocated, pfx)
+	}
+	delete(b.allocated, pfx)
+
+	key, level, _ := tableKey(pfx)
+	for ; level > b.level(b.parent.Bits()); level-- {
+		if !b.removeFree(buddy(key, level), level) {
+			break
+		}
+		key = key.and(mask6(level - 1))
+	}
+	b.addFree(key, level)
+
+	return nil
+}
+
+// Stats returns the utilization and fragmentation statistics.
+func (b *BuddyAllocator) Stats() (stats BuddyStats) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var free, largest float64
+	for level, keys := range b.free {
+		for _, key := range keys {
+			pfx := b.prefix(key, level)
+			size := prefixSize(pfx)
+
+			free += size
+			stats.FreeBlocks++
+
+			if size > largest {
+				largest = size
+				stats.LargestFree = pfx
+			}
+		}
+	}
+
+	stats.Allocations = len(b.allocated)
+
+	total := prefixSize(b.parent)
+	stats.Utilization = (total - free) / total
+	if free > 0 {
+		stats.Fragmentation = 1 - largest/free
+	}
+
+	return
+}
+
+// Snapshot returns the state of the allocator, the allocated
+// prefixes are sorted in CIDR order.
+func (b *BuddyAllocator) Snapshot() BuddySnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pfxs := make([]netip.Prefix, 0, len(b.allocated))
+	for pfx := range b.allocated {
+		pfxs = append(pfxs, pfx)
+	}
+	SortPrefixes(pfxs)
+
+	return BuddySnapshot{Parent: b.parent, Allocated: pfxs}
+}
+
+// Restore replaces the state of the allocator with the snapshot.
+//
+// The allocated prefixes must be disjoint and covered by the parent,
+// on error the allocator is unchanged.
+func (b *BuddyAllocator) Restore(s BuddySnapshot) error {
+	if !s.Parent.IsValid() {
+		return fmt.Errorf("%w: parent %s", ErrInvalidPrefix, s.Parent)
+	}
+
+	tmp := &BuddyAllocator{}
+	tmp.reset(s.Parent.Masked())
+	for _, pfx := range s.Allocated {
+		if err := tmp.allocateSpecific(pfx); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.parent, b.is4, b.free, b.allocated = tmp.parent, tmp.is4, tmp.free, tmp.allocated
+	return nil
+}
@@ -0,0 +1,164 @@
+package extnetip_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestBuddyAllocator(t *testing.T) {
+	t.Parallel()
+	b, err := extnetip.NewBuddyAllocator(mpp("10.0.0.0/24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []netip.Prefix
+	for _, bits := range []int{26, 28, 26, 27} {
+		pfx, err := b.Allocate(bits)
+		if err != nil {
+			t.Fatalf("Allocate(%d), unexpected error: %v", bits, err)
+		}
+		got = append(got, pfx)
+	}
+
+	want := pfxSlice("10.0.0.0/26", "10.0.0.64/28", "10.0.0.128/26", "10.0.0.96/27")
+	if !slices.Equal(got, want) {
+		t.Errorf("Allocate, got: %v, want: %v", got, want)
+	}
+
+	largest, free := 64.0, 80.0
+
+	stats := b.Stats()
+	wantStats := extnetip.BuddyStats{
+		Allocations:   4,
+		FreeBlocks:    2,
+		LargestFree:   mpp("10.0.0.192/26"),
+		Utilization:   176.0 / 256.0,
+		Fragmentation: 1 - largest/free,
+	}
+	if stats != wantStats {
+		t.Errorf("Stats(), got: %+v, want: %+v", stats, wantStats)
+	}
+
+	if _, err := b.Allocate(25); !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("Allocate(25), got: %v, want: %v", err, extnetip.ErrNoSpace)
+	}
+	if _, err := b.Allocate(23); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("Allocate(23), got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+
+	// release all, the buddies must merge back to the parent
+	for _, pfx := range got {
+		if err := b.Release(pfx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Release(got[0]); !errors.Is(err, extnetip.ErrNotAllocated) {
+		t.Errorf("Release twice, got: %v, want: %v", err, extnetip.ErrNotAllocated)
+	}
+
+	stats = b.Stats()
+	wantStats = extnetip.BuddyStats{FreeBlocks: 1, LargestFree: mpp("10.0.0.0/24")}
+	if stats != wantStats {
+		t.Errorf("Stats() after release, got: %+v, want: %+v", stats, wantStats)
+	}
+}
+
+func TestBuddyAllocateSpecific(t *testing.T) {
+	t.Parallel()
+	b, err := extnetip.NewBuddyAllocator(mpp("2001:db8::/48"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pfx  netip.Prefix
+		want error
+	}{
+		{mpp("2001:db8:0:1234::/64"), nil},
+		{mpp("2001:db8:0:1200::/56"), extnetip.ErrOverlap},
+		{mpp("2001:db8:0:1234::1/128"), extnetip.ErrOverlap},
+		{mpp("2001:db8:1::/64"), extnetip.ErrNotInPool},
+		{mpp("2001:db8::/47"), extnetip.ErrNotInPool},
+		{mpp("2001:db8:0:1235::/64"), nil},
+	}
+
+	for _, tt := range tests {
+		if err := b.AllocateSpecific(tt.pfx); !errors.Is(err, tt.want) {
+			t.Errorf("AllocateSpecific(%s), got: %v, want: %v", tt.pfx, err, tt.want)
+		}
+	}
+
+	// the smallest free block is the buddy of the split /63
+	if pfx, err := b.Allocate(64); err != nil || pfx != mpp("2001:db8:0:1236::/64") {
+		t.Errorf("Allocate(64), got: %s, %v, want: 2001:db8:0:1236::/64", pfx, err)
+	}
+}
+
+func TestBuddySnapshotRestore(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	b, err := extnetip.NewBuddyAllocator(mpp("10.0.0.0/16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var live []netip.Prefix
+	for range 200 {
+		if len(live) > 0 && prng.IntN(3) == 0 {
+			i := prng.IntN(len(live))
+			if err := b.Release(live[i]); err != nil {
+				t.Fatal(err)
+			}
+			live = slices.Delete(live, i, i+1)
+			continue
+		}
+		pfx, err := b.Allocate(20 + prng.IntN(9))
+		if err != nil {
+			continue
+		}
+		live = append(live, pfx)
+	}
+
+	snap := b.Snapshot()
+	if !slices.IsSortedFunc(snap.Allocated, extnetip.ComparePrefix) || len(snap.Allocated) != len(live) {
+		t.Fatalf("Snapshot(), unexpected allocations: %v", snap.Allocated)
+	}
+
+	restored, err := extnetip.NewBuddyAllocator(mpp("192.168.0.0/16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := restored.Stats(), b.Stats(); got != want {
+		t.Errorf("Restore, stats got: %+v, want: %+v", got, want)
+	}
+
+	// both allocators must continue identically
+	for range 20 {
+		p1, err1 := b.Allocate(24)
+		p2, err2 := restored.Allocate(24)
+		if p1 != p2 || (err1 == nil) != (err2 == nil) {
+			t.Fatalf("Allocate after Restore, got: %s, %v, want: %s, %v", p2, err2, p1, err1)
+		}
+	}
+
+	// invalid snapshot, the allocator must be unchanged
+	bad := extnetip.BuddySnapshot{Parent: mpp("10.0.0.0/16"), Allocated: pfxSlice("10.0.0.0/24", "10.0.0.0/25")}
+	before := restored.Stats()
+	if err := restored.Restore(bad); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("Restore(bad), got: %v, want: %v", err, extnetip.ErrOverlap)
+	}
+	if after := restored.Stats(); after != before {
+		t.Errorf("Restore(bad), state changed")
+	}
+}
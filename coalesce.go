@@ -0,0 +1,78 @@
+package extnetip
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// Coalesce returns an iterator over the segments of seq, where adjacent
+// and overlapping segments with equal values are merged.
+//
+// The input segments must be sorted by their first address, e.g. the
+// output of [RangeMap.All]. Overlapping segments with different values
+// are passed through unchanged, invalid segments are skipped.
+func Coalesce[V comparable](seq iter.Seq[Segment[V]]) iter.Seq[Segment[V]] {
+	return CoalesceFunc(seq, func(a, b V) bool { return a == b })
+}
+
+// CoalesceFunc is like [Coalesce] but uses the equality func eq to compare values.
+func CoalesceFunc[V any](seq iter.Seq[Segment[V]], eq func(a, b V) bool) iter.Seq[Segment[V]] {
+	return func(yield func(Segment[V]) bool) {
+		var cur interval
+		var curVal V
+		have := false
+
+		for seg := range seq {
+			if !seg.First.IsValid() || !seg.Last.IsValid() {
+				continue
+			}
+
+			next := interval{unwrap(seg.First), unwrap(seg.Last)}
+			if next.first.is4() != next.last.is4() || next.first.ip.compare(next.last.ip) == 1 {
+				continue
+			}
+
+			if have && eq(curVal, seg.Value) && cur.joins(next) {
+				if next.last.ip.compare(cur.last.ip) > 0 {
+					cur.last = next.last
+				}
+				continue
+			}
+
+			if have && !yield(Segment[V]{wrap(cur.first), wrap(cur.last), curVal}) {
+				return
+			}
+			cur, curVal, have = next, seg.Value, true
+		}
+
+		if have {
+			yield(Segment[V]{wrap(cur.first), wrap(cur.last), curVal})
+		}
+	}
+}
+
+// CoalescePrefixes returns an iterator over the minimal disjoint prefixes
+// for the prefixes in seq, where adjacent and overlapping prefixes with
+// equal values are merged.
+//
+// Nested prefixes are first resolved by longest-prefix-match, see [Flatten],
+// so each address keeps the value of its longest matching input prefix.
+// The input does not have to be sorted, for duplicate prefixes the last
+// value wins and invalid prefixes are skipped.
+func CoalescePrefixes[V comparable](seq iter.Seq2[netip.Prefix, V]) iter.Seq2[netip.Prefix, V] {
+	return CoalescePrefixesFunc(seq, func(a, b V) bool { return a == b })
+}
+
+// CoalescePrefixesFunc is like [CoalescePrefixes] but uses the equality
+// func eq to compare values.
+func CoalescePrefixesFunc[V any](seq iter.Seq2[netip.Prefix, V], eq func(a, b V) bool) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		for seg := range CoalesceFunc(Flatten(seq), eq) {
+			for pfx := range All(seg.First, seg.Last) {
+				if !yield(pfx, seg.Value) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package extnetip_test
+
+import (
+	"maps"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestCoalesce(t *testing.T) {
+	t.Parallel()
+	type seg = extnetip.Segment[string]
+
+	in := []seg{
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), "a"},
+		{mpa("10.0.0.10"), mpa("10.0.0.19"), "a"}, // adjacent, equal
+		{mpa("10.0.0.15"), mpa("10.0.0.29"), "a"}, // overlapping, equal
+		{mpa("10.0.0.30"), mpa("10.0.0.39"), "b"}, // adjacent, different
+		{mpa("10.0.0.41"), mpa("10.0.0.49"), "b"}, // gap
+		{mpa("10.0.0.50"), mpa("10.0.0.40"), "b"}, // invalid, skipped
+		{mpa("255.255.255.255"), mpa("255.255.255.255"), "b"},
+		{mpa("::"), mpa("::ffff"), "b"}, // next version
+		{mpa("::1:0"), mpa("::1:ffff"), "b"},
+	}
+
+	want := []seg{
+		{mpa("10.0.0.0"), mpa("10.0.0.29"), "a"},
+		{mpa("10.0.0.30"), mpa("10.0.0.39"), "b"},
+		{mpa("10.0.0.41"), mpa("10.0.0.49"), "b"},
+		{mpa("255.255.255.255"), mpa("255.255.255.255"), "b"},
+		{mpa("::"), mpa("::1:ffff"), "b"},
+	}
+
+	got := slices.Collect(extnetip.Coalesce(slices.Values(in)))
+	if !slices.Equal(got, want) {
+		t.Errorf("Coalesce, got: %v, want: %v", got, want)
+	}
+}
+
+func TestCoalescePrefixes(t *testing.T) {
+	t.Parallel()
+	in := map[netip.Prefix]int{
+		mpp("10.0.0.0/9"):         1,
+		mpp("10.128.0.0/9"):       1,
+		mpp("10.1.0.0/16"):        1, // covered, same value
+		mpp("11.0.0.0/8"):         2,
+		mpp("12.0.0.0/8"):         1,
+		mpp("12.1.0.0/16"):        3, // covered, different value
+		mpp("2001:db8::/33"):      4,
+		mpp("2001:db8:8000::/33"): 4,
+	}
+
+	pfxs := slices.SortedFunc(maps.Keys(in), extnetip.ComparePrefix)
+	seq := func(yield func(netip.Prefix, int) bool) {
+		for _, pfx := range pfxs {
+			if !yield(pfx, in[pfx]) {
+				return
+			}
+		}
+	}
+
+	var got []netip.Prefix
+	var vals []int
+	for pfx, val := range extnetip.CoalescePrefixes(seq) {
+		got = append(got, pfx)
+		vals = append(vals, val)
+	}
+
+	// the covered 12.1.0.0/16 is cut out of 12.0.0.0/8
+	want := pfxSlice(
+		"10.0.0.0/8", "11.0.0.0/8",
+		"12.0.0.0/16", "12.1.0.0/16", "12.2.0.0/15", "12.4.0.0/14", "12.8.0.0/13",
+		"12.16.0.0/12", "12.32.0.0/11", "12.64.0.0/10", "12.128.0.0/9",
+		"2001:db8::/32",
+	)
+	wantVals := []int{1, 2, 1, 3, 1, 1, 1, 1, 1, 1, 1, 4}
+
+	if !slices.Equal(got, want) || !slices.Equal(vals, wantVals) {
+		t.Errorf("CoalescePrefixes, got: %v %v, want: %v %v", got, vals, want, wantVals)
+	}
+}
+
+func TestCoalescePrefixesShadowed(t *testing.T) {
+	t.Parallel()
+	in := []struct {
+		pfx netip.Prefix
+		val string
+	}{
+		{mpp("10.0.0.0/25"), "B"},
+		{mpp("10.0.0.0/26"), "A"},
+		{mpp("10.0.0.64/26"), "A"},
+	}
+
+	seq := func(yield func(netip.Prefix, string) bool) {
+		for _, e := range in {
+			if !yield(e.pfx, e.val) {
+				return
+			}
+		}
+	}
+
+	var got []netip.Prefix
+	var vals []string
+	for pfx, val := range extnetip.CoalescePrefixes(seq) {
+		got = append(got, pfx)
+		vals = append(vals, val)
+	}
+
+	// B is completely shadowed by the more specifics
+	want := pfxSlice("10.0.0.0/25")
+	wantVals := []string{"A"}
+
+	if !slices.Equal(got, want) || !slices.Equal(vals, wantVals) {
+		t.Errorf("CoalescePrefixes, got: %v %v, want: %v %v", got, vals, want, wantVals)
+	}
+}
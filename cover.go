@@ -0,0 +1,106 @@
+package extnetip
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// CoverRange returns the smallest prefix covering the inclusive
+// IP range [first, last].
+//
+// It returns the zero value if an IP is invalid, if the IP versions
+// do not match or if first > last.
+//
+// The prefix length is the number of leading bits first and last
+// have in common, computed in uint128 space.
+func CoverRange(first, last netip.Addr) (pfx netip.Prefix) {
+	if !first.IsValid() || !last.IsValid() {
+		return
+	}
+
+	a := unwrap(first)
+	b := unwrap(last)
+
+	if a.is4() != b.is4() {
+		return
+	}
+
+	if a.ip.compare(b.ip) == 1 {
+		return
+	}
+
+	bits := a.ip.commonPrefixLen(b.ip)
+	if a.is4() {
+		bits -= 96 // adjust offset for IPv4
+	}
+
+	return netip.PrefixFrom(first, bits).Masked()
+}
+
+// CoverAddrs returns the smallest prefix covering all IP addresses
+// yielded by seq.
+//
+// It returns the zero value if seq yields no addresses, if any
+// address is invalid or if the IP versions do not match.
+func CoverAddrs(seq iter.Seq[netip.Addr]) (pfx netip.Prefix) {
+	var first addr
+	bits := -1
+
+	for ip := range seq {
+		if !ip.IsValid() {
+			return
+		}
+
+		a := unwrap(ip)
+
+		// first address, start with a host route
+		if bits == -1 {
+			first = a
+			bits = 128
+			continue
+		}
+
+		if a.is4() != first.is4() {
+			return
+		}
+
+		bits = min(bits, first.ip.commonPrefixLen(a.ip))
+	}
+
+	// empty sequence
+	if bits == -1 {
+		return
+	}
+
+	if first.is4() {
+		bits -= 96 // adjust offset for IPv4
+	}
+
+	return netip.PrefixFrom(wrap(first), bits).Masked()
+}
+
+// CommonPrefixAll returns the longest prefix shared by all prefixes
+// yielded by seq, see also [CommonPrefix].
+//
+// It returns the zero value if seq yields no prefixes, if any
+// prefix is invalid or if the IP versions do not match.
+func CommonPrefixAll(seq iter.Seq[netip.Prefix]) (pfx netip.Prefix) {
+	started := false
+
+	for p := range seq {
+		if !started {
+			if !p.IsValid() {
+				return netip.Prefix{}
+			}
+			pfx = p.Masked()
+			started = true
+			continue
+		}
+
+		if pfx = CommonPrefix(pfx, p); !pfx.IsValid() {
+			return
+		}
+	}
+
+	return
+}
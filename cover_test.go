@@ -0,0 +1,86 @@
+package extnetip_test
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestCoverRange(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		first netip.Addr
+		last  netip.Addr
+		want  netip.Prefix
+	}{
+		{netip.Addr{}, netip.Addr{}, netip.Prefix{}},                // invalid addrs
+		{mpa("0.0.0.1"), mpa("0.0.0.0"), netip.Prefix{}},            // wrong order
+		{mpa("0.0.0.1"), mpa("::1"), netip.Prefix{}},                // wrong versions
+		{mpa("0.0.0.1"), mpa("::ffff:1.2.3.4"), netip.Prefix{}},     // wrong versions
+		{mpa("10.0.0.1"), mpa("10.0.0.1"), mpp("10.0.0.1/32")},      // single address
+		{mpa("10.0.0.0"), mpa("10.255.255.255"), mpp("10.0.0.0/8")}, // exact prefix
+		{mpa("10.0.0.1"), mpa("10.0.0.19"), mpp("10.0.0.0/27")},
+		{mpa("10.0.0.0"), mpa("11.10.255.255"), mpp("10.0.0.0/7")},
+		{mpa("127.0.0.1"), mpa("128.0.0.0"), mpp("0.0.0.0/0")},
+		{mpa("fe80::1"), mpa("fe80::8"), mpp("fe80::/124")},
+		{mpa("::"), mpa("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), mpp("::/0")},
+	}
+
+	for _, tt := range tests {
+		got := extnetip.CoverRange(tt.first, tt.last)
+		if got != tt.want {
+			t.Errorf("CoverRange(%s, %s), got: %s, want: %s", tt.first, tt.last, got, tt.want)
+		}
+	}
+}
+
+func TestCoverAddrs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		addrs []netip.Addr
+		want  netip.Prefix
+	}{
+		{"empty", nil, netip.Prefix{}},
+		{"invalid", []netip.Addr{mpa("10.0.0.1"), {}}, netip.Prefix{}},
+		{"wrong versions", []netip.Addr{mpa("10.0.0.1"), mpa("::1")}, netip.Prefix{}},
+		{"single v4", []netip.Addr{mpa("10.0.0.1")}, mpp("10.0.0.1/32")},
+		{"single v6", []netip.Addr{mpa("2001:db8::1")}, mpp("2001:db8::1/128")},
+		{"unsorted v4", []netip.Addr{mpa("10.0.0.9"), mpa("10.0.0.1"), mpa("10.0.0.5")}, mpp("10.0.0.0/28")},
+		{"unsorted v6", []netip.Addr{mpa("2001:db8:1::1"), mpa("2001:db8::1"), mpa("2001:db8:2::")}, mpp("2001:db8::/46")},
+	}
+
+	for _, tt := range tests {
+		got := extnetip.CoverAddrs(slices.Values(tt.addrs))
+		if got != tt.want {
+			t.Errorf("CoverAddrs(%s), got: %s, want: %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCommonPrefixAll(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		pfxs []netip.Prefix
+		want netip.Prefix
+	}{
+		{"empty", nil, netip.Prefix{}},
+		{"invalid first", []netip.Prefix{{}, mpp("10.0.0.0/8")}, netip.Prefix{}},
+		{"invalid last", []netip.Prefix{mpp("10.0.0.0/8"), {}}, netip.Prefix{}},
+		{"wrong versions", []netip.Prefix{mpp("10.0.0.0/8"), mpp("::/64")}, netip.Prefix{}},
+		{"single non-canonical", pfxSlice("192.168.1.123/24"), mpp("192.168.1.0/24")},
+		{"v4", pfxSlice("192.168.1.0/24", "192.168.2.0/24", "192.168.3.128/25"), mpp("192.168.0.0/22")},
+		{"v4 nested", pfxSlice("10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"), mpp("10.0.0.0/8")},
+		{"v6", pfxSlice("2001:db8:1::/48", "2001:db8:2::/48", "2001:db8:3::/64"), mpp("2001:db8::/46")},
+	}
+
+	for _, tt := range tests {
+		got := extnetip.CommonPrefixAll(slices.Values(tt.pfxs))
+		if got != tt.want {
+			t.Errorf("CommonPrefixAll(%s), got: %s, want: %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package extnetip
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// checkSLAACPrefix returns an error wrapping [ErrInvalidPrefix]
+// if prefix is not an IPv6 /64 prefix.
+func checkSLAACPrefix(prefix netip.Prefix) error {
+	if !prefix.IsValid() || !prefix.Addr().Is6() || prefix.Addr().Is4In6() || prefix.Bits() != 64 {
+		return fmt.Errorf("%w: %s, must be an IPv6 /64", ErrInvalidPrefix, prefix)
+	}
+	return nil
+}
+
+// withIID returns the address of the /64 prefix with the interface identifier iid.
+func withIID(prefix netip.Prefix, iid uint64) netip.Addr {
+	u := unwrap(prefix.Masked().Addr()).ip
+	return wrap(fromUint128(u.setField(64, 64, iid), false))
+}
+
+// EUI64Addr returns the SLAAC address in the /64 prefix with the modified
+// EUI-64 interface identifier of mac, RFC 4291 appendix A.
+//
+// A 48-bit MAC address is extended with ff:fe in the middle, the
+// universal/local bit is inverted, e.g. 00:11:22:33:44:55 is
+// 211:22ff:fe33:4455. A 64-bit EUI-64 is used as is, apart from the
+// universal/local bit.
+//
+// It returns an error wrapping [ErrInvalidPrefix] if prefix is not an IPv6 /64,
+// or [ErrInvalidAddr] if mac is not a 48-bit or 64-bit hardware address.
+func EUI64Addr(prefix netip.Prefix, mac net.HardwareAddr) (netip.Addr, error) {
+	if err := checkSLAACPrefix(prefix); err != nil {
+		return netip.Addr{}, err
+	}
+
+	var eui [8]byte
+	switch len(mac) {
+	case 6:
+		copy(eui[:3], mac[:3])
+		eui[3], eui[4] = 0xff, 0xfe
+		copy(eui[5:], mac[3:])
+	case 8:
+		copy(eui[:], mac)
+	default:
+		return netip.Addr{}, fmt.Errorf("%w: hardware address %s, must be 48 or 64 bits", ErrInvalidAddr, mac)
+	}
+	eui[0] ^= 0x02 // invert the universal/local bit
+
+	return withIID(prefix, binary.BigEndian.Uint64(eui[:])), nil
+}
+
+// MACFromEUI64 returns the 48-bit MAC address from the modified EUI-64
+// interface identifier of the IPv6 address addr, the inverse of [EUI64Addr].
+//
+// It returns ok=false if addr is not an IPv6 address or if the interface
+// identifier has no ff:fe in the middle.
+func MACFromEUI64(addr netip.Addr) (mac net.HardwareAddr, ok bool) {
+	u, ok := unwrap6(addr)
+	if !ok || u.field(88, 16) != 0xfffe {
+		return nil, false
+	}
+
+	var eui [8]byte
+	binary.BigEndian.PutUint64(eui[:], u.field(64, 64))
+	eui[0] ^= 0x02 // invert the universal/local bit
+
+	return net.HardwareAddr{eui[0], eui[1], eui[2], eui[5], eui[6], eui[7]}, true
+}
+
+// StableAddr returns the address in the /64 prefix with a semantically
+// opaque, stable interface identifier, RFC 7217.
+//
+// The interface identifier is the first 64 bits of
+// HMAC-SHA256(secret, prefix | iface | networkID | dadCounter), so it is
+// stable for the same inputs but changes with the prefix. The variable
+// length fields iface and networkID are length prefixed, to keep the
+// concatenation unambiguous. The networkID is optional, e.g. the SSID.
+//
+// The dadCounter is incremented after a duplicate address detection failure.
+// Reserved interface identifiers, RFC 5453, are skipped by incrementing
+// the counter internally.
+//
+// It returns an error wrapping [ErrInvalidPrefix] if prefix is not an IPv6 /64.
+func StableAddr(prefix netip.Prefix, iface string, networkID []byte, dadCounter uint8, secret []byte) (netip.Addr, error) {
+	if err := checkSLAACPrefix(prefix); err != nil {
+		return netip.Addr{}, err
+	}
+
+	pfx := prefix.Masked().Addr().As16()
+
+	msg := make([]byte, 0, 8+2+len(iface)+2+len(networkID)+1)
+	msg = append(msg, pfx[:8]...)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(iface)))
+	msg = append(msg, iface...)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(networkID)))
+	msg = append(msg, networkID...)
+	msg = append(msg, dadCounter)
+
+	mac := hmac.New(sha256.New, secret)
+	prf := func(msg []byte) uint64 {
+		mac.Reset()
+		mac.Write(msg)
+		return binary.BigEndian.Uint64(mac.Sum(nil))
+	}
+
+	return withIID(prefix, stableIID(msg, prf)), nil
+}
+
+// stableIID returns the first interface identifier prf(msg) that is not
+// reserved, the last byte of msg is the DAD counter and is incremented
+// for each reserved value.
+func stableIID(msg []byte, prf func([]byte) uint64) uint64 {
+	for {
+		if iid := prf(msg); !isReservedIID(iid) {
+			return iid
+		}
+		msg[len(msg)-1]++
+	}
+}
+
+// isReservedIID reports whether iid is a reserved IPv6 interface identifier,
+// RFC 5453: the subnet-router anycast, the range 0200:5eff:fe00:0000 to
+// 0200:5eff:feff:ffff and the reserved subnet anycast addresses
+// fdff:ffff:ffff:ff80 to fdff:ffff:ffff:ffff.
+func isReservedIID(iid uint64) bool {
+	return iid == 0 ||
+		iid>>24 == 0x02005efffe ||
+		iid>>7 == 0xfdffffffffffff80>>7
+}
@@ -0,0 +1,46 @@
+package extnetip
+
+import "testing"
+
+func TestIsReservedIID(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		iid  uint64
+		want bool
+	}{
+		{0, true},
+		{1, false},
+		{0x02005efffdffffff, false},
+		{0x02005efffe000000, true},
+		{0x02005efffeffffff, true},
+		{0x02005effff000000, false},
+		{0xfdffffffffffff7f, false},
+		{0xfdffffffffffff80, true},
+		{0xfdffffffffffffff, true},
+		{0xfe00000000000000, false},
+		{0xffffffffffffffff, false},
+	}
+
+	for _, tt := range tests {
+		if got := isReservedIID(tt.iid); got != tt.want {
+			t.Errorf("isReservedIID(%#x), got: %v, want: %v", tt.iid, got, tt.want)
+		}
+	}
+}
+
+func TestStableIIDReserved(t *testing.T) {
+	t.Parallel()
+	// the pseudo random values by DAD counter, the first two are reserved
+	values := []uint64{0x02005efffe123456, 0xfdffffffffffff90, 0x1234}
+	prf := func(msg []byte) uint64 {
+		return values[msg[len(msg)-1]]
+	}
+
+	msg := []byte{0xaa, 0}
+	if got := stableIID(msg, prf); got != 0x1234 {
+		t.Errorf("stableIID, got: %#x, want: %#x", got, 0x1234)
+	}
+	if msg[1] != 2 {
+		t.Errorf("stableIID, DAD counter got: %d, want: 2", msg[1])
+	}
+}
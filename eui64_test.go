@@ -0,0 +1,121 @@
+package extnetip_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func mustMAC(s string) net.HardwareAddr {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+	return mac
+}
+
+func TestEUI64Addr(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		prefix netip.Prefix
+		mac    net.HardwareAddr
+		want   netip.Addr
+	}{
+		{mpp("2001:db8::/64"), mustMAC("00:11:22:33:44:55"), mpa("2001:db8::211:22ff:fe33:4455")},
+		{mpp("2001:db8:1:2::ff/64"), mustMAC("02:11:22:33:44:55"), mpa("2001:db8:1:2:11:22ff:fe33:4455")},
+		{mpp("fe80::/64"), mustMAC("00:11:22:33:44:55:66:77"), mpa("fe80::211:2233:4455:6677")},
+	}
+
+	for _, tt := range tests {
+		got, err := extnetip.EUI64Addr(tt.prefix, tt.mac)
+		if err != nil {
+			t.Fatalf("EUI64Addr(%s, %s), unexpected error: %v", tt.prefix, tt.mac, err)
+		}
+		if got != tt.want {
+			t.Errorf("EUI64Addr(%s, %s), got: %s, want: %s", tt.prefix, tt.mac, got, tt.want)
+		}
+
+		if len(tt.mac) != 6 {
+			continue
+		}
+		mac, ok := extnetip.MACFromEUI64(got)
+		if !ok || !bytes.Equal(mac, tt.mac) {
+			t.Errorf("MACFromEUI64(%s), got: %s, %v, want: %s", got, mac, ok, tt.mac)
+		}
+	}
+
+	for _, pfx := range []netip.Prefix{{}, mpp("10.0.0.0/8"), mpp("2001:db8::/48"), mpp("2001:db8::/80")} {
+		if _, err := extnetip.EUI64Addr(pfx, mustMAC("00:11:22:33:44:55")); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+			t.Errorf("EUI64Addr(%s), got: %v, want: %v", pfx, err, extnetip.ErrInvalidPrefix)
+		}
+	}
+	if _, err := extnetip.EUI64Addr(mpp("2001:db8::/64"), net.HardwareAddr{1, 2, 3}); !errors.Is(err, extnetip.ErrInvalidAddr) {
+		t.Errorf("EUI64Addr short MAC, got: %v, want: %v", err, extnetip.ErrInvalidAddr)
+	}
+}
+
+func TestMACFromEUI64(t *testing.T) {
+	t.Parallel()
+	for _, ip := range []netip.Addr{{}, mpa("10.0.0.1"), mpa("2001:db8::1"), mpa("fe80::211:2233:4455:6677")} {
+		if mac, ok := extnetip.MACFromEUI64(ip); ok {
+			t.Errorf("MACFromEUI64(%s), got: %s, want: not ok", ip, mac)
+		}
+	}
+}
+
+func TestStableAddr(t *testing.T) {
+	t.Parallel()
+	secret := []byte("0123456789abcdef")
+	prefix := mpp("2001:db8:1:2::/64")
+
+	a, err := extnetip.StableAddr(prefix, "eth0", nil, 0, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prefix.Contains(a) {
+		t.Fatalf("StableAddr, got: %s, not in %s", a, prefix)
+	}
+
+	// stable for the same inputs
+	if b, _ := extnetip.StableAddr(prefix, "eth0", nil, 0, secret); b != a {
+		t.Errorf("StableAddr not stable, got: %s, want: %s", b, a)
+	}
+
+	// any changed input changes the interface identifier
+	variants := []netip.Addr{a}
+	add := func(p netip.Prefix, iface string, netID []byte, dad uint8, key []byte) {
+		t.Helper()
+		v, err := extnetip.StableAddr(p, iface, netID, dad, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Contains(v) {
+			t.Fatalf("StableAddr, got: %s, not in %s", v, p)
+		}
+		variants = append(variants, extnetip.HostBits(v, 64))
+	}
+	variants[0] = extnetip.HostBits(a, 64)
+
+	add(mpp("2001:db8:1:3::/64"), "eth0", nil, 0, secret)
+	add(prefix, "eth1", nil, 0, secret)
+	add(prefix, "eth", []byte("0"), 0, secret) // no ambiguous concatenation
+	add(prefix, "eth0", []byte("ssid"), 0, secret)
+	add(prefix, "eth0", nil, 1, secret)
+	add(prefix, "eth0", nil, 0, []byte("another secret"))
+
+	seen := map[netip.Addr]bool{}
+	for _, v := range variants {
+		if seen[v] {
+			t.Errorf("StableAddr, duplicate interface identifier %s", v)
+		}
+		seen[v] = true
+	}
+
+	if _, err := extnetip.StableAddr(mpp("2001:db8::/56"), "eth0", nil, 0, secret); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("StableAddr /56, got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+}
@@ -65,3 +65,13 @@ func ExampleAll() {
 	// 10.1.13.224/29
 	// 10.1.13.232/31
 }
+
+func ExampleCoverRange() {
+	first := netip.MustParseAddr("10.1.0.0")
+	last := netip.MustParseAddr("10.1.13.233")
+
+	fmt.Println(extnetip.CoverRange(first, last))
+
+	// Output:
+	// 10.1.0.0/20
+}
@@ -0,0 +1,104 @@
+package extnetip
+
+import (
+	"iter"
+	"net/netip"
+	"slices"
+)
+
+// Flatten returns an iterator over the disjoint segments covered by the
+// overlapping routes, each segment with the value of its longest prefix match.
+//
+// The routes do not have to be sorted, they are collected and sorted in CIDR
+// order. For duplicate prefixes the last value wins, invalid prefixes are
+// skipped. The segments are yielded in ascending order, IPv4 before IPv6.
+//
+// Adjacent segments may carry equal values, use [Coalesce] or [CoalesceFunc]
+// on the result to get a canonical form, e.g. to compare two routing tables
+// for equivalence.
+func Flatten[V any](routes iter.Seq2[netip.Prefix, V]) iter.Seq[Segment[V]] {
+	return func(yield func(Segment[V]) bool) {
+		type route struct {
+			pfx netip.Prefix
+			val V
+		}
+
+		var rs []route
+		for pfx, val := range routes {
+			if pfx.IsValid() {
+				rs = append(rs, route{pfx.Masked(), val})
+			}
+		}
+		slices.SortStableFunc(rs, func(a, b route) int { return ComparePrefix(a.pfx, b.pfx) })
+
+		// active routes, each one nested in the route below
+		type active struct {
+			iv  interval
+			val V
+		}
+		var stack []active
+
+		var pos addr
+		exhausted := false // pos is past the last address of this IP version
+
+		// emit yields the segment [pos, last] with the value of the top of stack
+		emit := func(last addr) bool {
+			if exhausted || pos.ip.compare(last.ip) > 0 {
+				return true
+			}
+			top := stack[len(stack)-1]
+			if !yield(Segment[V]{wrap(pos), wrap(last), top.val}) {
+				return false
+			}
+			exhausted = last.ip == lastIP(last.is4())
+			pos = fromUint128(last.ip.addOne(), last.is4())
+			return true
+		}
+
+		for i, r := range rs {
+			// duplicate prefix, the last one wins
+			if i+1 < len(rs) && rs[i+1].pfx == r.pfx {
+				continue
+			}
+
+			iv := prefixInterval(r.pfx)
+
+			// pop the routes not covering this one
+			for len(stack) > 0 && !stack[len(stack)-1].iv.contains(iv.first) {
+				if !emit(stack[len(stack)-1].iv.last) {
+					return
+				}
+				stack = stack[:len(stack)-1]
+			}
+
+			// the gap in front of this route belongs to the covering route
+			if len(stack) > 0 && pos.ip.compare(iv.first.ip) < 0 && !emit(fromUint128(iv.first.ip.subOne(), iv.first.is4())) {
+				return
+			}
+
+			pos, exhausted = iv.first, false
+			stack = append(stack, active{iv, r.val})
+		}
+
+		for len(stack) > 0 {
+			if !emit(stack[len(stack)-1].iv.last) {
+				return
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// FlattenPrefixes is like [Flatten], but yields the minimal prefixes
+// of each segment with its value, see [All].
+func FlattenPrefixes[V any](routes iter.Seq2[netip.Prefix, V]) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		for seg := range Flatten(routes) {
+			for pfx := range All(seg.First, seg.Last) {
+				if !yield(pfx, seg.Value) {
+					return
+				}
+			}
+		}
+	}
+}
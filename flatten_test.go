@@ -0,0 +1,113 @@
+package extnetip_test
+
+import (
+	"maps"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestFlatten(t *testing.T) {
+	t.Parallel()
+	type seg = extnetip.Segment[string]
+
+	routes := map[netip.Prefix]string{
+		mpp("0.0.0.0/0"):     "default",
+		mpp("10.0.0.0/8"):    "a",
+		mpp("10.1.0.0/16"):   "b",
+		mpp("10.1.255.0/24"): "c",
+		mpp("::/0"):          "default6",
+		mpp("::/1"):          "d",
+	}
+
+	got := slices.Collect(extnetip.Flatten(maps.All(routes)))
+	want := []seg{
+		{mpa("0.0.0.0"), mpa("9.255.255.255"), "default"},
+		{mpa("10.0.0.0"), mpa("10.0.255.255"), "a"},
+		{mpa("10.1.0.0"), mpa("10.1.254.255"), "b"},
+		{mpa("10.1.255.0"), mpa("10.1.255.255"), "c"},
+		{mpa("10.2.0.0"), mpa("10.255.255.255"), "a"},
+		{mpa("11.0.0.0"), mpa("255.255.255.255"), "default"},
+		{mpa("::"), mpa("7fff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), "d"},
+		{mpa("8000::"), mpa("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), "default6"},
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("Flatten, got: %v, want: %v", got, want)
+	}
+}
+
+func TestFlattenPrefixes(t *testing.T) {
+	t.Parallel()
+
+	// two equivalent routing tables
+	t1 := map[netip.Prefix]int{
+		mpp("10.0.0.0/8"):  1,
+		mpp("10.0.0.0/9"):  2,
+		mpp("10.1.0.0/16"): 2,
+	}
+	t2 := map[netip.Prefix]int{
+		mpp("10.0.0.0/9"):   2,
+		mpp("10.128.0.0/9"): 1,
+	}
+
+	flat := func(routes map[netip.Prefix]int) (pfxs []netip.Prefix) {
+		for pfx := range extnetip.CoalescePrefixes(extnetip.FlattenPrefixes(maps.All(routes))) {
+			pfxs = append(pfxs, pfx)
+		}
+		return
+	}
+
+	if got1, got2 := flat(t1), flat(t2); !slices.Equal(got1, got2) {
+		t.Errorf("FlattenPrefixes, tables not equivalent: %v, %v", got1, got2)
+	}
+}
+
+// TestFlattenRandom compares Flatten with a brute force LPM reference.
+func TestFlattenRandom(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	for range 200 {
+		routes := map[netip.Prefix]int{}
+		for n := range prng.IntN(20) {
+			a := netip.AddrFrom4([4]byte{10, 0, 0, byte(prng.IntN(256))})
+			routes[netip.PrefixFrom(a, 24+prng.IntN(9)).Masked()] = n
+		}
+
+		var ref [256]int
+		var refBits [256]int
+		for i := range refBits {
+			ref[i], refBits[i] = -1, -1
+		}
+		for pfx, val := range routes {
+			for i := range 256 {
+				if pfx.Contains(netip.AddrFrom4([4]byte{10, 0, 0, byte(i)})) && pfx.Bits() > refBits[i] {
+					ref[i], refBits[i] = val, pfx.Bits()
+				}
+			}
+		}
+
+		var got [256]int
+		for i := range got {
+			got[i] = -1
+		}
+		var prev netip.Addr
+		for seg := range extnetip.Flatten(maps.All(routes)) {
+			if prev.IsValid() && !prev.Less(seg.First) {
+				t.Fatalf("Flatten(%v), segments not disjoint at %s", routes, seg.First)
+			}
+			prev = seg.Last
+			for i := int(seg.First.As4()[3]); i <= int(seg.Last.As4()[3]); i++ {
+				got[i] = seg.Value
+			}
+		}
+
+		if got != ref {
+			t.Fatalf("Flatten(%v), got: %v, want: %v", routes, got, ref)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package extnetip
+
+import (
+	"iter"
+	"net/netip"
+	"slices"
+)
+
+// Gaps returns an iterator over the minimal sorted prefixes covering the
+// free space in parent, not covered by any of the used prefixes.
+//
+// The used prefixes do not have to be sorted and may overlap each other
+// or reach beyond parent, invalid prefixes are skipped. If parent is invalid,
+// the iterator yields no results.
+func Gaps(parent netip.Prefix, used iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix] {
+	return func(yield func(netip.Prefix) bool) {
+		if !parent.IsValid() {
+			return
+		}
+
+		// only the used prefixes overlapping the parent are relevant
+		var children []netip.Prefix
+		for pfx := range used {
+			if pfx.IsValid() && pfx.Overlaps(parent) {
+				children = append(children, pfx)
+			}
+		}
+		SortPrefixes(children)
+
+		for pfx := range DiffSeq(slices.Values([]netip.Prefix{parent}), slices.Values(children)) {
+			if !yield(pfx) {
+				return
+			}
+		}
+	}
+}
+
+// LargestFree returns the largest free prefix in parent, not covered by any
+// of the used prefixes, see [Gaps]. For free prefixes of the same size the
+// lowest one is returned.
+//
+// It returns the zero value if parent is invalid or if there is no free space.
+func LargestFree(parent netip.Prefix, used iter.Seq[netip.Prefix]) (pfx netip.Prefix) {
+	for gap := range Gaps(parent, used) {
+		if !pfx.IsValid() || gap.Bits() < pfx.Bits() {
+			pfx = gap
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package extnetip_test
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestGaps(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		parent  netip.Prefix
+		used    []netip.Prefix
+		want    []netip.Prefix
+		largest netip.Prefix
+	}{
+		{netip.Prefix{}, pfxSlice("10.0.0.0/8"), nil, netip.Prefix{}},
+		{mpp("10.0.0.0/16"), nil, pfxSlice("10.0.0.0/16"), mpp("10.0.0.0/16")},
+		{mpp("10.0.0.0/16"), pfxSlice("10.0.0.0/8"), nil, netip.Prefix{}},
+		{
+			mpp("10.0.0.0/16"),
+			pfxSlice("10.0.128.0/24", "10.0.0.0/24", "10.0.1.0/25", "2001:db8::/32", "192.168.0.0/16"),
+			pfxSlice(
+				"10.0.1.128/25", "10.0.2.0/23", "10.0.4.0/22", "10.0.8.0/21", "10.0.16.0/20",
+				"10.0.32.0/19", "10.0.64.0/18", "10.0.129.0/24", "10.0.130.0/23", "10.0.132.0/22",
+				"10.0.136.0/21", "10.0.144.0/20", "10.0.160.0/19", "10.0.192.0/18",
+			),
+			mpp("10.0.64.0/18"),
+		},
+		{
+			mpp("2001:db8::/48"),
+			pfxSlice("2001:db8::/49", "2001:db8:0:c000::/50"),
+			pfxSlice("2001:db8:0:8000::/50"),
+			mpp("2001:db8:0:8000::/50"),
+		},
+	}
+
+	for _, tt := range tests {
+		got := slices.Collect(extnetip.Gaps(tt.parent, slices.Values(tt.used)))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Gaps(%s, %v), got: %v, want: %v", tt.parent, tt.used, got, tt.want)
+		}
+
+		largest := extnetip.LargestFree(tt.parent, slices.Values(tt.used))
+		if largest != tt.largest {
+			t.Errorf("LargestFree(%s, %v), got: %s, want: %s", tt.parent, tt.used, largest, tt.largest)
+		}
+	}
+}
+
+// TestLargestFreeRandom compares LargestFree with a brute force search
+// over all aligned blocks.
+func TestLargestFreeRandom(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+	parent := mpp("10.0.0.0/24")
+
+	for range 500 {
+		var used []netip.Prefix
+		for range prng.IntN(10) {
+			a := netip.AddrFrom4([4]byte{10, 0, 0, byte(prng.IntN(256))})
+			used = append(used, netip.PrefixFrom(a, 24+prng.IntN(9)).Masked())
+		}
+
+		var want netip.Prefix
+	search:
+		for bits := 24; bits <= 32; bits++ {
+			for i := 0; i < 256; i += 1 << (32 - bits) {
+				cand := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, 0, 0, byte(i)}), bits)
+				free := true
+				for _, u := range used {
+					if u.Overlaps(cand) {
+						free = false
+						break
+					}
+				}
+				if free {
+					want = cand
+					break search
+				}
+			}
+		}
+
+		if got := extnetip.LargestFree(parent, slices.Values(used)); got != want {
+			t.Fatalf("LargestFree(%s, %v), got: %s, want: %s", parent, used, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package extnetip
+
+import "net/netip"
+
+// hostMask returns the uint128 mask of the host bits for the
+// prefix length bits, it returns ok=false if bits is out of range.
+func hostMask(a addr, bits int) (mask uint128, ok bool) {
+	if bits < 0 || bits > 128 || a.is4() && bits > 32 {
+		return uint128{}, false
+	}
+	if a.is4() {
+		bits += 96 // IPv4 addresses are embedded in IPv6 space with a 96-bit prefix
+	}
+	return mask6(bits).not(), true
+}
+
+// HostBits returns the host part of addr for the prefix length bits,
+// the network bits are set to zero, e.g. ::1234 for 2001:db8::1234 and 64.
+//
+// It returns the zero value if addr is invalid or bits is
+// out of range for the IP version.
+func HostBits(addr netip.Addr, bits int) netip.Addr {
+	if !addr.IsValid() {
+		return netip.Addr{}
+	}
+
+	zero := netip.IPv6Unspecified()
+	if addr.Is4() {
+		zero = netip.IPv4Unspecified()
+	}
+
+	pfx := netip.PrefixFrom(zero, bits)
+	if !pfx.IsValid() {
+		return netip.Addr{}
+	}
+	return graft(pfx, unwrap(addr))
+}
+
+// NetworkBits returns the network part of addr for the prefix length bits,
+// the host bits are set to zero, the same as the masked prefix address.
+//
+// It returns the zero value if addr is invalid or bits is
+// out of range for the IP version.
+func NetworkBits(addr netip.Addr, bits int) netip.Addr {
+	if !addr.IsValid() {
+		return netip.Addr{}
+	}
+	a := unwrap(addr)
+
+	mask, ok := hostMask(a, bits)
+	if !ok {
+		return netip.Addr{}
+	}
+	return wrap(fromUint128(a.ip.and(mask.not()), a.is4()))
+}
+
+// Graft returns the address with the network bits of prefix and the host
+// bits of hostFrom, e.g. 2001:db8:2::1234 for 2001:db8:2::/64 and
+// 2001:db8:1::1234. The host bits are relative to the prefix length.
+//
+// It returns the zero value if prefix or hostFrom is invalid or
+// if the IP versions do not match.
+func Graft(prefix netip.Prefix, hostFrom netip.Addr) netip.Addr {
+	if !prefix.IsValid() || !hostFrom.IsValid() || prefix.Addr().Is4() != hostFrom.Is4() {
+		return netip.Addr{}
+	}
+	return graft(prefix, unwrap(hostFrom))
+}
+
+// graft returns the address with the network bits of the valid
+// prefix and the host bits of a, a has the IP version of prefix.
+func graft(prefix netip.Prefix, a addr) netip.Addr {
+	mask, _ := hostMask(a, prefix.Bits())
+
+	u := unwrap(prefix.Addr()).ip.and(mask.not()).or(a.ip.and(mask))
+	return wrap(fromUint128(u, a.is4()))
+}
@@ -0,0 +1,91 @@
+package extnetip_test
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestHostNetworkBits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		addr    netip.Addr
+		bits    int
+		host    netip.Addr
+		network netip.Addr
+	}{
+		{mpa("2001:db8:1:2::1234"), 64, mpa("::1234"), mpa("2001:db8:1:2::")},
+		{mpa("2001:db8:1:2::1234"), 0, mpa("2001:db8:1:2::1234"), mpa("::")},
+		{mpa("2001:db8:1:2::1234"), 128, mpa("::"), mpa("2001:db8:1:2::1234")},
+		{mpa("2001:db8:1:2::1234"), 52, mpa("::2:0:0:0:1234"), mpa("2001:db8:1::")},
+		{mpa("192.168.1.77"), 24, mpa("0.0.0.77"), mpa("192.168.1.0")},
+		{mpa("192.168.1.77"), 20, mpa("0.0.1.77"), mpa("192.168.0.0")},
+		{mpa("192.168.1.77"), 32, mpa("0.0.0.0"), mpa("192.168.1.77")},
+		{mpa("192.168.1.77"), 0, mpa("192.168.1.77"), mpa("0.0.0.0")},
+		{mpa("::ffff:192.168.1.77"), 120, mpa("::4d"), mpa("::ffff:192.168.1.0")},
+		{mpa("192.168.1.77"), 33, netip.Addr{}, netip.Addr{}},
+		{mpa("2001:db8::1"), -1, netip.Addr{}, netip.Addr{}},
+		{mpa("2001:db8::1"), 129, netip.Addr{}, netip.Addr{}},
+		{netip.Addr{}, 0, netip.Addr{}, netip.Addr{}},
+	}
+
+	for _, tt := range tests {
+		if got := extnetip.HostBits(tt.addr, tt.bits); got != tt.host {
+			t.Errorf("HostBits(%s, %d), got: %s, want: %s", tt.addr, tt.bits, got, tt.host)
+		}
+		if got := extnetip.NetworkBits(tt.addr, tt.bits); got != tt.network {
+			t.Errorf("NetworkBits(%s, %d), got: %s, want: %s", tt.addr, tt.bits, got, tt.network)
+		}
+	}
+}
+
+func TestGraft(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		prefix   netip.Prefix
+		hostFrom netip.Addr
+		want     netip.Addr
+	}{
+		{mpp("2001:db8:2::/64"), mpa("2001:db8:1::1234"), mpa("2001:db8:2::1234")},
+		{mpp("2001:db8:2::ff/64"), mpa("2001:db8:1::1234"), mpa("2001:db8:2::1234")}, // non-canonical
+		{mpp("2001:db8:2::/48"), mpa("2001:db8:1:5::1"), mpa("2001:db8:2:5::1")},
+		{mpp("172.20.0.0/16"), mpa("10.1.2.3"), mpa("172.20.2.3")},
+		{mpp("172.20.0.0/0"), mpa("10.1.2.3"), mpa("10.1.2.3")},
+		{mpp("172.20.0.0/32"), mpa("10.1.2.3"), mpa("172.20.0.0")},
+		{mpp("172.20.0.0/16"), mpa("2001:db8::1"), netip.Addr{}},
+		{mpp("172.20.0.0/16"), mpa("::ffff:10.1.2.3"), netip.Addr{}},
+		{netip.Prefix{}, mpa("10.1.2.3"), netip.Addr{}},
+		{mpp("172.20.0.0/16"), netip.Addr{}, netip.Addr{}},
+	}
+
+	for _, tt := range tests {
+		if got := extnetip.Graft(tt.prefix, tt.hostFrom); got != tt.want {
+			t.Errorf("Graft(%s, %s), got: %s, want: %s", tt.prefix, tt.hostFrom, got, tt.want)
+		}
+	}
+}
+
+func TestGraftRandom(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	for range 1000 {
+		var b [16]byte
+		for i := range b {
+			b[i] = byte(prng.UintN(256))
+		}
+		addr := netip.AddrFrom16(b)
+		if prng.IntN(2) == 0 {
+			addr = netip.AddrFrom4([4]byte(b[:4]))
+		}
+		bits := prng.IntN(addr.BitLen() + 1)
+
+		// the network and host parts recombine to the address
+		pfx := netip.PrefixFrom(extnetip.NetworkBits(addr, bits), bits)
+		if got := extnetip.Graft(pfx, extnetip.HostBits(addr, bits)); got != addr {
+			t.Fatalf("Graft(%s, HostBits(%s, %d)), got: %s, want: %s", pfx, addr, bits, got, addr)
+		}
+	}
+}
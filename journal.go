@@ -0,0 +1,586 @@
+package extnetip
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJournal is returned if a journal record can't be parsed.
+	ErrJournal = errors.New("extnetip: invalid journal record")
+
+	// ErrJournalFailed is returned if the journal file is in an unknown
+	// state after a failed write, until a [Journal.Compact] succeeds.
+	ErrJournalFailed = errors.New("extnetip: journal failed")
+)
+
+// RecordOp is the operation of a journal [Record].
+type RecordOp uint8
+
+const (
+	// RecordAllocate marks the prefix as allocated.
+	RecordAllocate RecordOp = iota + 1
+
+	// RecordRelease marks the allocated prefix as free.
+	RecordRelease
+
+	// RecordGrow replaces the single allocated prefix covered by
+	// the prefix with the prefix, see [Allocator.Grow].
+	RecordGrow
+
+	// RecordLease leases the address to the owner until expiry.
+	RecordLease
+
+	// RecordUnlease releases the lease of the owner.
+	RecordUnlease
+
+	// RecordReserve excludes the address from leasing.
+	RecordReserve
+)
+
+var recordOpNames = [...]string{
+	RecordAllocate: "allocate",
+	RecordRelease:  "release",
+	RecordGrow:     "grow",
+	RecordLease:    "lease",
+	RecordUnlease:  "unlease",
+	RecordReserve:  "reserve",
+}
+
+// String returns the name of the operation as used in the journal.
+func (op RecordOp) String() string {
+	if op == 0 || int(op) >= len(recordOpNames) {
+		return "RecordOp(" + strconv.Itoa(int(op)) + ")"
+	}
+	return recordOpNames[op]
+}
+
+// Record is a state change of an [Allocator] or a [Pool].
+//
+// Prefix is set for the allocation operations, Lease for the
+// lease operations, [RecordUnlease] only uses the owner and
+// [RecordReserve] only uses the address.
+type Record struct {
+	Op     RecordOp
+	Prefix netip.Prefix
+	Lease  Lease
+}
+
+// MarshalText implements encoding.TextMarshaler, the record is encoded
+// as a single line with the operation followed by its arguments:
+//
+//	allocate 10.0.0.0/24
+//	release 10.0.0.0/24
+//	grow 10.0.0.0/23
+//	lease 10.0.1.5 2024-01-01T12:00:00Z "owner"
+//	unlease "owner"
+//	reserve 10.0.1.1
+//
+// Prefixes and addresses use the netip text format, the expiry is
+// RFC 3339 in UTC and the owner is a Go quoted string.
+func (r Record) MarshalText() ([]byte, error) {
+	var b []byte
+	b = append(b, r.Op.String()...)
+
+	switch r.Op {
+	case RecordAllocate, RecordRelease, RecordGrow:
+		if !r.Prefix.IsValid() {
+			return nil, fmt.Errorf("%w: %s %s", ErrInvalidPrefix, r.Op, r.Prefix)
+		}
+		b = append(b, ' ')
+		b = r.Prefix.AppendTo(b)
+
+	case RecordLease:
+		if !r.Lease.Addr.IsValid() {
+			return nil, fmt.Errorf("%w: %s, invalid address", ErrJournal, r.Op)
+		}
+		b = append(b, ' ')
+		b = r.Lease.Addr.AppendTo(b)
+		b = append(b, ' ')
+		b = r.Lease.Expiry.UTC().AppendFormat(b, time.RFC3339Nano)
+		b = append(b, ' ')
+		b = strconv.AppendQuote(b, r.Lease.Owner)
+
+	case RecordUnlease:
+		b = append(b, ' ')
+		b = strconv.AppendQuote(b, r.Lease.Owner)
+
+	case RecordReserve:
+		if !r.Lease.Addr.IsValid() {
+			return nil, fmt.Errorf("%w: %s, invalid address", ErrJournal, r.Op)
+		}
+		b = append(b, ' ')
+		b = r.Lease.Addr.AppendTo(b)
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrJournal, r.Op)
+	}
+
+	return b, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler,
+// see [Record.MarshalText] for the format.
+func (r *Record) UnmarshalText(text []byte) error {
+	s := string(text)
+	name, args, _ := strings.Cut(s, " ")
+
+	idx := slices.Index(recordOpNames[:], name)
+	if idx <= 0 {
+		return fmt.Errorf("%w: %q", ErrJournal, s)
+	}
+	op := RecordOp(idx)
+
+	var err error
+	rec := Record{Op: op}
+
+	switch op {
+	case RecordAllocate, RecordRelease, RecordGrow:
+		rec.Prefix, err = netip.ParsePrefix(args)
+
+	case RecordLease:
+		ip, rest, _ := strings.Cut(args, " ")
+		ts, owner, _ := strings.Cut(rest, " ")
+
+		if rec.Lease.Addr, err = netip.ParseAddr(ip); err != nil {
+			break
+		}
+		if rec.Lease.Expiry, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			break
+		}
+		rec.Lease.Owner, err = strconv.Unquote(owner)
+
+	case RecordUnlease:
+		rec.Lease.Owner, err = strconv.Unquote(args)
+
+	case RecordReserve:
+		rec.Lease.Addr, err = netip.ParseAddr(args)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", ErrJournal, s, err)
+	}
+
+	*r = rec
+	return nil
+}
+
+// Persister persists the state changes of an [Allocator] or a [Pool],
+// see [Allocator.SetPersister] and [Pool.SetPersister].
+//
+// Persist is called before the change is applied, the change is
+// rejected if Persist returns an error.
+type Persister interface {
+	Persist(r Record) error
+}
+
+// Journal is a [Persister] writing an append-only journal of records to
+// a local file, one record per line, see [Record.MarshalText].
+//
+// The journal keeps the current state in memory and validates each record
+// against it, e.g. recovered allocations must not overlap. The file is
+// compacted to the current state on open and after a number of appended
+// records, by writing a temporary file and renaming it.
+//
+// One journal can persist both an [Allocator] and a [Pool].
+// A Journal is safe for concurrent use.
+type Journal struct {
+	mu           sync.Mutex
+	path         string
+	file         journalFile
+	open         func(name string) (journalFile, error) // opens the file for appending
+	failed       error                                  // wraps ErrJournalFailed
+	compactAfter int
+	appended     int
+	state        journalState
+}
+
+// journalFile is the journal file opened for appending, an *os.File.
+type journalFile interface {
+	io.Writer
+	io.Seeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// openAppend opens the file name for appending.
+func openAppend(name string) (journalFile, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// journalState is the state of a journal after replaying all records.
+type journalState struct {
+	allocated Table[struct{}]
+	leases    map[string]journalLease // by owner
+	reserved  map[netip.Addr]struct{}
+}
+
+// journalLease is a lease, released leases are kept as sticky addresses.
+type journalLease struct {
+	Lease
+	released bool
+}
+
+// OpenJournal opens or creates the journal file at path and recovers its state.
+//
+// It returns an error wrapping [ErrJournal] if a record can't be parsed,
+// a torn last line without newline from an interrupted write is ignored.
+// Records conflicting with the state, e.g. overlapping allocations, return
+// the corresponding error like [ErrOverlap].
+//
+// The journal is compacted after compactAfter appended records,
+// if compactAfter <= 0 only on open and by [Journal.Compact].
+func OpenJournal(path string, compactAfter int) (*Journal, error) {
+	j := &Journal{
+		path:         path,
+		open:         openAppend,
+		compactAfter: compactAfter,
+		state: journalState{
+			leases:   map[string]journalLease{},
+			reserved: map[netip.Addr]struct{}{},
+		},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// ignore a torn last line
+	if i := bytes.LastIndexByte(data, '\n'); i != len(data)-1 {
+		data = data[:i+1]
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		var r Record
+		if err := r.UnmarshalText(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if err := j.state.apply(r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := j.compact(); err != nil {
+		if j.file != nil {
+			_ = j.file.Close()
+		}
+		return nil, err
+	}
+	return j, nil
+}
+
+// Persist validates the record, appends it to the journal file
+// and syncs the file, see [Persister].
+//
+// The periodic compaction is best-effort, once the record is appended
+// Persist succeeds even if the compaction fails.
+//
+// A record that can't be written and synced is truncated from the file.
+// If that fails too, the journal is marked as failed and all following
+// records are rejected with an error wrapping [ErrJournalFailed], until
+// [Journal.Compact] rewrites the file from the current state.
+func (j *Journal) Persist(r Record) error {
+	line, err := r.MarshalText()
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.failed != nil {
+		return j.failed
+	}
+	if j.file == nil {
+		return os.ErrClosed
+	}
+
+	if err := j.state.check(r); err != nil {
+		return err
+	}
+
+	if err := j.append(line); err != nil {
+		return err
+	}
+
+	_ = j.state.apply(r) // already checked
+	j.appended++
+
+	// the record is committed, a failed compaction is retried
+	// with the next append or by Compact
+	if j.compactAfter > 0 && j.appended >= j.compactAfter {
+		_ = j.compact()
+	}
+	return nil
+}
+
+// append writes and syncs the line, on failure the file is truncated to
+// its previous size, so the rejected record is not recovered on open.
+func (j *Journal) append(line []byte) error {
+	size, err := j.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	_, err = j.file.Write(line)
+	if err == nil {
+		err = j.file.Sync()
+	}
+	if err == nil {
+		return nil
+	}
+
+	if terr := j.file.Truncate(size); terr != nil {
+		j.failed = fmt.Errorf("%w: %w, truncate: %w", ErrJournalFailed, err, terr)
+		return j.failed
+	}
+	return err
+}
+
+// Records returns an iterator over a snapshot of the compacted
+// journal state, suitable for [Allocator.Replay] and [Pool.Replay].
+//
+// The reserved addresses come first, then the allocations in CIDR order
+// and the leases in address order, released leases are followed by
+// their unlease record.
+func (j *Journal) Records() iter.Seq[Record] {
+	j.mu.Lock()
+	recs := j.state.records()
+	j.mu.Unlock()
+
+	return slices.Values(recs)
+}
+
+// Compact rewrites the journal file with the records of the current state,
+// this also recovers a failed journal.
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil && j.failed == nil {
+		return os.ErrClosed
+	}
+	return j.compact()
+}
+
+// compact writes the current state to a temporary file, renames it
+// to the journal path and reopens the journal for appending.
+//
+// If the rename fails, the previous journal file is reopened, it is
+// still complete and the compaction can be retried. If the file can't
+// be reopened, the journal is marked as failed.
+func (j *Journal) compact() error {
+	var buf bytes.Buffer
+	for _, r := range j.state.records() {
+		line, err := r.MarshalText()
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := j.path + ".tmp"
+	if err := writeFileSync(tmp, buf.Bytes()); err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		_ = j.file.Close()
+		j.file = nil
+	}
+
+	renameErr := os.Rename(tmp, j.path)
+	if renameErr == nil {
+		syncDir(filepath.Dir(j.path))
+	}
+
+	f, err := j.open(j.path)
+	if err != nil {
+		j.failed = fmt.Errorf("%w: reopen: %w", ErrJournalFailed, err)
+		return j.failed
+	}
+	j.file = f
+
+	if renameErr != nil {
+		return renameErr
+	}
+	j.failed = nil
+	j.appended = 0
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		if j.failed != nil {
+			j.failed = nil
+			return nil
+		}
+		return os.ErrClosed
+	}
+	err := j.file.Close()
+	j.file = nil
+	j.failed = nil
+	return err
+}
+
+// writeFileSync writes data to the file name and syncs it to disk.
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs the directory to persist a rename, best effort,
+// directories can't be synced on all platforms.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
+
+// check returns an error if the record conflicts with the state.
+func (s *journalState) check(r Record) error {
+	switch r.Op {
+	case RecordAllocate:
+		if c, ok := conflict(&s.allocated, r.Prefix.Masked()); ok {
+			return fmt.Errorf("%w: %s overlaps allocated %s", ErrOverlap, r.Prefix, c)
+		}
+
+	case RecordRelease:
+		if _, ok := s.allocated.Get(r.Prefix); !ok {
+			return fmt.Errorf("%w: %s", ErrNotAllocated, r.Prefix)
+		}
+
+	case RecordGrow:
+		if _, err := grownFrom(&s.allocated, r.Prefix.Masked()); err != nil {
+			return err
+		}
+
+	case RecordLease:
+		if _, ok := s.reserved[r.Lease.Addr]; ok {
+			return fmt.Errorf("%w: lease of reserved %s", ErrOverlap, r.Lease.Addr)
+		}
+
+	case RecordUnlease:
+		if l, ok := s.leases[r.Lease.Owner]; !ok || l.released {
+			return fmt.Errorf("%w: %q", ErrNotLeased, r.Lease.Owner)
+		}
+	}
+	return nil
+}
+
+// apply checks the record and applies it to the state.
+func (s *journalState) apply(r Record) error {
+	if err := s.check(r); err != nil {
+		return err
+	}
+
+	switch r.Op {
+	case RecordAllocate:
+		s.allocated.Insert(r.Prefix, struct{}{})
+
+	case RecordRelease:
+		s.allocated.Delete(r.Prefix)
+
+	case RecordGrow:
+		pfx, _ := grownFrom(&s.allocated, r.Prefix.Masked())
+		s.allocated.Delete(pfx)
+		s.allocated.Insert(r.Prefix, struct{}{})
+
+	case RecordLease:
+		s.dropLease(r.Lease.Addr)
+		s.leases[r.Lease.Owner] = journalLease{Lease: r.Lease}
+
+	case RecordUnlease:
+		l := s.leases[r.Lease.Owner]
+		l.released = true
+		s.leases[r.Lease.Owner] = l
+
+	case RecordReserve:
+		s.dropLease(r.Lease.Addr)
+		s.reserved[r.Lease.Addr] = struct{}{}
+	}
+	return nil
+}
+
+// dropLease removes the lease of the address ip, if any.
+func (s *journalState) dropLease(ip netip.Addr) {
+	for owner, l := range s.leases {
+		if l.Addr == ip {
+			delete(s.leases, owner)
+		}
+	}
+}
+
+// records returns the records to rebuild the state.
+func (s *journalState) records() []Record {
+	var recs []Record
+
+	reserved := make([]netip.Addr, 0, len(s.reserved))
+	for ip := range s.reserved {
+		reserved = append(reserved, ip)
+	}
+	SortAddrs(reserved)
+	for _, ip := range reserved {
+		recs = append(recs, Record{Op: RecordReserve, Lease: Lease{Addr: ip}})
+	}
+
+	for pfx := range s.allocated.All2() {
+		recs = append(recs, Record{Op: RecordAllocate, Prefix: pfx})
+	}
+
+	leases := make([]journalLease, 0, len(s.leases))
+	for _, l := range s.leases {
+		leases = append(leases, l)
+	}
+	slices.SortFunc(leases, func(a, b journalLease) int { return compareAddr(a.Addr, b.Addr) })
+
+	for _, l := range leases {
+		recs = append(recs, Record{Op: RecordLease, Lease: l.Lease})
+		if l.released {
+			recs = append(recs, Record{Op: RecordUnlease, Lease: Lease{Owner: l.Owner}})
+		}
+	}
+
+	return recs
+}
@@ -0,0 +1,139 @@
+package extnetip
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// faultyFile injects errors into a journal file.
+type faultyFile struct {
+	journalFile
+	syncErr  error
+	truncErr error
+}
+
+func (f *faultyFile) Sync() error {
+	if f.syncErr != nil {
+		return f.syncErr
+	}
+	return f.journalFile.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.truncErr != nil {
+		return f.truncErr
+	}
+	return f.journalFile.Truncate(size)
+}
+
+func allocRecord(s string) Record {
+	return Record{Op: RecordAllocate, Prefix: netip.MustParsePrefix(s)}
+}
+
+func readJournal(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestJournalSyncFailure(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "journal")
+	errSync := errors.New("sync failed")
+
+	j, err := OpenJournal(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	if err := j.Persist(allocRecord("10.0.0.0/24")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed record is truncated, the journal stays usable
+	f := &faultyFile{journalFile: j.file, syncErr: errSync}
+	j.file = f
+	if err := j.Persist(allocRecord("10.0.1.0/24")); !errors.Is(err, errSync) {
+		t.Fatalf("Persist, got: %v, want: %v", err, errSync)
+	}
+	if got, want := readJournal(t, path), "allocate 10.0.0.0/24\n"; got != want {
+		t.Errorf("journal, got: %q, want: %q", got, want)
+	}
+
+	f.syncErr = nil
+	if err := j.Persist(allocRecord("10.0.1.0/24")); err != nil {
+		t.Fatalf("Persist after recovery, got: %v", err)
+	}
+	if got, want := readJournal(t, path), "allocate 10.0.0.0/24\nallocate 10.0.1.0/24\n"; got != want {
+		t.Errorf("journal, got: %q, want: %q", got, want)
+	}
+}
+
+func TestJournalTruncateFailure(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j, err := OpenJournal(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	// the failed record can't be removed, the journal is failed
+	j.file = &faultyFile{journalFile: j.file, syncErr: errors.New("sync"), truncErr: errors.New("truncate")}
+	if err := j.Persist(allocRecord("10.0.0.0/24")); !errors.Is(err, ErrJournalFailed) {
+		t.Fatalf("Persist, got: %v, want: %v", err, ErrJournalFailed)
+	}
+	if err := j.Persist(allocRecord("10.0.1.0/24")); !errors.Is(err, ErrJournalFailed) {
+		t.Fatalf("Persist on failed journal, got: %v, want: %v", err, ErrJournalFailed)
+	}
+
+	// compaction rewrites the file without the rejected record
+	if err := j.Compact(); err != nil {
+		t.Fatalf("Compact, got: %v", err)
+	}
+	if got := readJournal(t, path); got != "" {
+		t.Errorf("journal, got: %q, want: empty", got)
+	}
+	if err := j.Persist(allocRecord("10.0.1.0/24")); err != nil {
+		t.Fatalf("Persist after Compact, got: %v", err)
+	}
+}
+
+func TestJournalReopenFailure(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "journal")
+	errOpen := errors.New("open failed")
+
+	j, err := OpenJournal(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	j.open = func(string) (journalFile, error) { return nil, errOpen }
+	if err := j.Compact(); !errors.Is(err, ErrJournalFailed) || !errors.Is(err, errOpen) {
+		t.Fatalf("Compact, got: %v, want: %v", err, ErrJournalFailed)
+	}
+	if err := j.Persist(allocRecord("10.0.0.0/24")); !errors.Is(err, ErrJournalFailed) {
+		t.Fatalf("Persist, got: %v, want: %v", err, ErrJournalFailed)
+	}
+
+	j.open = openAppend
+	if err := j.Compact(); err != nil {
+		t.Fatalf("Compact, got: %v", err)
+	}
+	if err := j.Persist(allocRecord("10.0.0.0/24")); err != nil {
+		t.Fatalf("Persist after Compact, got: %v", err)
+	}
+	if got, want := readJournal(t, path), "allocate 10.0.0.0/24\n"; got != want {
+		t.Errorf("journal, got: %q, want: %q", got, want)
+	}
+}
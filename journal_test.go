@@ -0,0 +1,355 @@
+package extnetip_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestRecordText(t *testing.T) {
+	t.Parallel()
+	expiry := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		rec  extnetip.Record
+		text string
+	}{
+		{extnetip.Record{Op: extnetip.RecordAllocate, Prefix: mpp("10.0.0.0/24")}, "allocate 10.0.0.0/24"},
+		{extnetip.Record{Op: extnetip.RecordRelease, Prefix: mpp("2001:db8::/48")}, "release 2001:db8::/48"},
+		{extnetip.Record{Op: extnetip.RecordGrow, Prefix: mpp("10.0.0.0/23")}, "grow 10.0.0.0/23"},
+		{
+			extnetip.Record{Op: extnetip.RecordLease, Lease: extnetip.Lease{Addr: mpa("10.0.1.5"), Owner: "host a", Expiry: expiry}},
+			`lease 10.0.1.5 2024-01-01T12:00:00Z "host a"`,
+		},
+		{extnetip.Record{Op: extnetip.RecordUnlease, Lease: extnetip.Lease{Owner: `x"y`}}, `unlease "x\"y"`},
+		{extnetip.Record{Op: extnetip.RecordReserve, Lease: extnetip.Lease{Addr: mpa("fe80::1")}}, "reserve fe80::1"},
+	}
+
+	for _, tt := range tests {
+		b, err := tt.rec.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText(%v), unexpected error: %v", tt.rec, err)
+		}
+		if string(b) != tt.text {
+			t.Errorf("MarshalText, got: %s, want: %s", b, tt.text)
+		}
+
+		var got extnetip.Record
+		if err := got.UnmarshalText(b); err != nil {
+			t.Fatalf("UnmarshalText(%s), unexpected error: %v", b, err)
+		}
+		if got != tt.rec {
+			t.Errorf("UnmarshalText(%s), got: %+v, want: %+v", b, got, tt.rec)
+		}
+	}
+
+	for _, s := range []string{"", "free 10.0.0.0/8", "allocate 10.0.0.0", "lease 10.0.0.1 yesterday \"a\"", "unlease a"} {
+		var r extnetip.Record
+		if err := r.UnmarshalText([]byte(s)); !errors.Is(err, extnetip.ErrJournal) {
+			t.Errorf("UnmarshalText(%q), got: %v, want: %v", s, err, extnetip.ErrJournal)
+		}
+	}
+
+	if _, err := (extnetip.Record{}).MarshalText(); !errors.Is(err, extnetip.ErrJournal) {
+		t.Errorf("MarshalText(zero), got: %v, want: %v", err, extnetip.ErrJournal)
+	}
+}
+
+func TestJournalAllocator(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "alloc.journal")
+
+	j, err := extnetip.OpenJournal(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetPersister(j)
+
+	for _, bits := range []int{24, 24, 26, 24} {
+		if _, err := a.Allocate(bits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Release(mpp("10.0.1.0/24")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Grow(mpp("10.0.2.0/26"), 24); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AllocateSpecific(mpp("10.0.128.0/17")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := slices.Collect(a.All())
+
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// recover
+	j, err = extnetip.OpenJournal(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	b, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Replay(j.Records()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := slices.Collect(b.All()); !slices.Equal(got, want) {
+		t.Errorf("Replay, got: %v, want: %v", got, want)
+	}
+
+	// the compacted file holds just the state
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != len(want) {
+		t.Errorf("compacted journal, got %d lines, want: %d\n%s", n, len(want), data)
+	}
+
+	// a second allocator over the same journal can't allocate overlapping prefixes
+	b.SetPersister(j)
+	c, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetPersister(j)
+	if err := c.AllocateSpecific(mpp("10.0.0.0/23")); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("AllocateSpecific, got: %v, want: %v", err, extnetip.ErrOverlap)
+	}
+	if n := len(slices.Collect(c.All())); n != 0 {
+		t.Errorf("rejected allocation applied, got %d prefixes", n)
+	}
+}
+
+func TestJournalPool(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "pool.journal")
+
+	j, err := extnetip.OpenJournal(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, clock := newTestPool(t, "10.0.0.1", "10.0.0.10")
+	p.SetPersister(j)
+
+	if err := p.Reserve(mpa("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, owner := range []string{"a", "b", "c"} {
+		if _, err := p.Lease(owner, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Release("b"); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Minute)
+	if _, err := p.Renew("c", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	want := slices.Collect(p.Leases())
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = extnetip.OpenJournal(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	q, err := extnetip.NewPool(mpa("10.0.0.1"), mpa("10.0.0.10"), clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Replay(j.Records()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := slices.Collect(q.Leases())
+	if len(got) != len(want) {
+		t.Fatalf("Replay, got: %+v, want: %+v", got, want)
+	}
+	for i := range got {
+		if got[i].Addr != want[i].Addr || got[i].Owner != want[i].Owner || !got[i].Expiry.Equal(want[i].Expiry) {
+			t.Errorf("Replay, got: %+v, want: %+v", got[i], want[i])
+		}
+	}
+
+	// the released address is still sticky for b
+	l, err := q.Lease("b", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Addr != mpa("10.0.0.3") {
+		t.Errorf("Lease(b) after recovery, got: %s, want: %s", l.Addr, mpa("10.0.0.3"))
+	}
+
+	// the reserved address is recovered
+	if _, ok := q.Lookup(mpa("10.0.0.1")); ok {
+		t.Errorf("Lookup reserved, got: true, want: false")
+	}
+	if err := q.Replay(slices.Values([]extnetip.Record{
+		{Op: extnetip.RecordLease, Lease: extnetip.Lease{Addr: mpa("10.0.0.1"), Owner: "x"}},
+	})); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("Replay lease of reserved, got: %v, want: %v", err, extnetip.ErrOverlap)
+	}
+}
+
+func TestOpenJournalRecovery(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"empty", "", nil},
+		{"comments", "# journal\n\nallocate 10.0.0.0/8\n", nil},
+		{"torn last line", "allocate 10.0.0.0/8\nallocate 11.0", nil},
+		{"invalid record", "allocate 10.0.0.0/8\nallocate 11.0\n", extnetip.ErrJournal},
+		{"overlap", "allocate 10.0.0.0/8\nallocate 10.1.0.0/16\n", extnetip.ErrOverlap},
+		{"release unallocated", "release 10.0.0.0/8\n", extnetip.ErrNotAllocated},
+		{"grow overlap", "allocate 10.0.0.0/24\nallocate 10.0.1.0/24\ngrow 10.0.0.0/23\n", extnetip.ErrOverlap},
+		{"unlease unknown", "unlease \"a\"\n", extnetip.ErrNotLeased},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "journal")
+		if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		j, err := extnetip.OpenJournal(path, 0)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("OpenJournal(%s), unexpected error: %v", tt.name, err)
+				continue
+			}
+			_ = j.Close()
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("OpenJournal(%s), got: %v, want: %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestJournalClosed(t *testing.T) {
+	t.Parallel()
+	j, err := extnetip.OpenJournal(filepath.Join(t.TempDir(), "journal"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetPersister(j)
+
+	if _, err := a.Allocate(16); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Allocate, got: %v, want: %v", err, os.ErrClosed)
+	}
+	if n := len(slices.Collect(a.All())); n != 0 {
+		t.Errorf("Allocate not persisted, got %d prefixes, want: 0", n)
+	}
+
+	s, err := extnetip.NewAllocator(extnetip.SparseLeftmost, mpp("10.0.0.0/8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetPersister(j)
+
+	if pfx, err := s.Allocate(16); !errors.Is(err, os.ErrClosed) || pfx.IsValid() {
+		t.Errorf("sparse Allocate, got: %s, %v, want: invalid prefix, %v", pfx, err, os.ErrClosed)
+	}
+	if err := j.Compact(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Compact, got: %v, want: %v", err, os.ErrClosed)
+	}
+}
+
+func TestJournalCompactFailure(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "alloc.journal")
+
+	j, err := extnetip.OpenJournal(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	a, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetPersister(j)
+
+	// the temporary file of the compaction can't be written
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Compact(); err == nil {
+		t.Fatal("Compact, got: nil, want: error")
+	}
+
+	// the appended records are committed despite the failed compaction
+	for _, want := range pfxSlice("10.0.0.0/26", "10.0.0.64/26") {
+		if got, err := a.Allocate(26); err != nil || got != want {
+			t.Fatalf("Allocate(26), got: %s, %v, want: %s", got, err, want)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "allocate 10.0.0.0/26\nallocate 10.0.0.64/26\n"; string(data) != want {
+		t.Errorf("journal, got: %q, want: %q", data, want)
+	}
+
+	// the compaction is retried
+	if err := os.Remove(path + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Compact(); err != nil {
+		t.Fatalf("Compact, got: %v, want: nil", err)
+	}
+	if got, err := a.Allocate(26); err != nil || got != mpp("10.0.0.128/26") {
+		t.Errorf("Allocate(26), got: %s, %v, want: 10.0.0.128/26", got, err)
+	}
+
+	b, err := extnetip.NewAllocator(extnetip.FirstFit, mpp("10.0.0.0/24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Replay(j.Records()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := slices.Collect(b.All()), slices.Collect(a.All()); !slices.Equal(got, want) {
+		t.Errorf("Replay, got: %v, want: %v", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+package extnetip
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net/netip"
+)
+
+// ErrInvalidRule is returned for an invalid [MAPRule].
+var ErrInvalidRule = errors.New("extnetip: invalid MAP rule")
+
+// MAPRule is a MAP-E or MAP-T mapping rule, RFC 7597 and RFC 7599.
+//
+// The End-user IPv6 prefix of a CE is the rule IPv6 prefix followed by
+// the EA bits (embedded address bits), the EA bits are the IPv4 address
+// suffix after the rule IPv4 prefix, followed by the PSID (port set ID).
+//
+// The IPv4 suffix length is 32 - IPv4Prefix.Bits() and must not exceed
+// EABitsLen, CEs with an IPv4 prefix shorter than /32 are not supported.
+type MAPRule struct {
+	// IPv6Prefix is the rule IPv6 prefix.
+	IPv6Prefix netip.Prefix
+
+	// IPv4Prefix is the rule IPv4 prefix.
+	IPv4Prefix netip.Prefix
+
+	// EABitsLen is the length of the EA bits, the End-user IPv6
+	// prefix length is IPv6Prefix.Bits() + EABitsLen, at most 64.
+	EABitsLen int
+
+	// PSIDOffset is the number of high-order port bits before the
+	// PSID, the ports with these bits all zero are excluded, RFC 7597
+	// recommends 6, excluding the system ports 0 to 1023.
+	PSIDOffset int
+}
+
+// check returns the PSID length, or an error wrapping [ErrInvalidRule].
+func (r MAPRule) check() (k int, err error) {
+	if !r.IPv6Prefix.IsValid() || !r.IPv6Prefix.Addr().Is6() {
+		return 0, fmt.Errorf("%w: IPv6 prefix %s", ErrInvalidRule, r.IPv6Prefix)
+	}
+	if !r.IPv4Prefix.IsValid() || !r.IPv4Prefix.Addr().Is4() {
+		return 0, fmt.Errorf("%w: IPv4 prefix %s", ErrInvalidRule, r.IPv4Prefix)
+	}
+	if r.EABitsLen < 0 || r.IPv6Prefix.Bits()+r.EABitsLen > 64 {
+		return 0, fmt.Errorf("%w: EA-bits length %d with IPv6 prefix %s", ErrInvalidRule, r.EABitsLen, r.IPv6Prefix)
+	}
+
+	k = r.EABitsLen - (32 - r.IPv4Prefix.Bits())
+	if k < 0 {
+		return 0, fmt.Errorf("%w: EA-bits length %d is shorter than the IPv4 suffix of %s", ErrInvalidRule, r.EABitsLen, r.IPv4Prefix)
+	}
+	if r.PSIDOffset < 0 || r.PSIDOffset+k > 16 {
+		return 0, fmt.Errorf("%w: PSID offset %d with PSID length %d", ErrInvalidRule, r.PSIDOffset, k)
+	}
+	return k, nil
+}
+
+// Validate returns an error wrapping [ErrInvalidRule] if the rule is invalid.
+func (r MAPRule) Validate() error {
+	_, err := r.check()
+	return err
+}
+
+// PSIDLen returns the PSID length, the EA-bits length minus
+// the IPv4 suffix length, 0 for an invalid rule.
+func (r MAPRule) PSIDLen() int {
+	k, _ := r.check()
+	return k
+}
+
+// EndUserPrefix returns the End-user IPv6 prefix of the CE with
+// the IPv4 address ipv4 and the port set ID psid.
+//
+// It returns an error wrapping [ErrInvalidRule] for an invalid rule, or
+// [ErrInvalidAddr] if ipv4 is not within the rule IPv4 prefix or psid
+// exceeds the PSID length.
+func (r MAPRule) EndUserPrefix(ipv4 netip.Addr, psid uint16) (netip.Prefix, error) {
+	k, err := r.check()
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if !ipv4.Is4() || !r.IPv4Prefix.Contains(ipv4) {
+		return netip.Prefix{}, fmt.Errorf("%w: %s is not in %s", ErrInvalidAddr, ipv4, r.IPv4Prefix)
+	}
+	if uint64(psid)>>k != 0 {
+		return netip.Prefix{}, fmt.Errorf("%w: PSID %#x exceeds %d bits", ErrInvalidAddr, psid, k)
+	}
+
+	p := 32 - r.IPv4Prefix.Bits()
+	suffix := uint64(addr4ToUint32(ipv4)) & (1<<p - 1)
+	ea := suffix<<k | uint64(psid)
+
+	r6 := r.IPv6Prefix.Bits()
+	u := unwrap(r.IPv6Prefix.Masked().Addr()).ip
+	u = u.setField(r6, r.EABitsLen, ea)
+
+	return netip.PrefixFrom(wrap(fromUint128(u, false)), r6+r.EABitsLen), nil
+}
+
+// CE returns the IPv4 address and the port set ID of the CE with the
+// End-user IPv6 prefix delegated, the inverse of [MAPRule.EndUserPrefix].
+//
+// The delegated prefix may be longer than the End-user IPv6 prefix, e.g. a
+// MAP IPv6 address as /128. It returns an error wrapping [ErrInvalidRule]
+// for an invalid rule, or [ErrInvalidPrefix] if delegated is not within
+// the rule IPv6 prefix or shorter than the End-user IPv6 prefix.
+func (r MAPRule) CE(delegated netip.Prefix) (ipv4 netip.Addr, psid uint16, err error) {
+	k, err := r.check()
+	if err != nil {
+		return netip.Addr{}, 0, err
+	}
+
+	r6 := r.IPv6Prefix.Bits()
+	if !delegated.IsValid() || delegated.Bits() < r6+r.EABitsLen {
+		return netip.Addr{}, 0, fmt.Errorf("%w: %s is no End-user prefix of %s", ErrInvalidPrefix, delegated, r.IPv6Prefix)
+	}
+
+	u, ok := unwrap6(delegated.Addr())
+	if !ok || !covers6(r.IPv6Prefix, u) {
+		return netip.Addr{}, 0, fmt.Errorf("%w: %s is not in %s", ErrInvalidPrefix, delegated, r.IPv6Prefix)
+	}
+
+	ea := u.field(r6, r.EABitsLen)
+
+	base := addr4ToUint32(r.IPv4Prefix.Masked().Addr())
+	ipv4 = uint32ToAddr4(base | uint32(ea>>k))
+	psid = uint16(ea & (1<<k - 1))
+
+	return ipv4, psid, nil
+}
+
+// MAPAddr returns the MAP IPv6 address of the CE with the End-user IPv6
+// prefix delegated, with the subnet ID zero.
+//
+// The interface identifier is 16 zero bits, the IPv4 address and the
+// right-aligned PSID, RFC 7597 section 6, e.g. 2001:db8:12:3400:0:c000:212:34
+// for the CE 192.0.2.18 with PSID 0x34 in 2001:db8:12:3400::/56.
+func (r MAPRule) MAPAddr(delegated netip.Prefix) (netip.Addr, error) {
+	ipv4, psid, err := r.CE(delegated)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	u := unwrap(delegated.Addr()).ip.and(mask6(r.IPv6Prefix.Bits() + r.EABitsLen))
+	u = u.setField(80, 32, uint64(addr4ToUint32(ipv4)))
+	u = u.setField(112, 16, uint64(psid))
+
+	return wrap(fromUint128(u, false)), nil
+}
+
+// PSID returns the port set ID of the port, ok is false for an
+// invalid rule or a port excluded by the PSID offset.
+func (r MAPRule) PSID(port uint16) (psid uint16, ok bool) {
+	k, err := r.check()
+	if err != nil {
+		return 0, false
+	}
+
+	a := r.PSIDOffset
+	if a > 0 && port>>(16-a) == 0 {
+		return 0, false
+	}
+
+	m := 16 - a - k
+	return uint16((uint32(port) >> m) & (1<<k - 1)), true
+}
+
+// Ports returns an iterator over the inclusive port ranges of the port
+// set psid, in ascending order, RFC 7597 section 5.1.
+//
+// Each range has 2^(16 - PSIDOffset - PSIDLen) ports, with a PSID offset
+// there is one range for each value of the offset bits except zero.
+// It yields nothing for an invalid rule or psid.
+func (r MAPRule) Ports(psid uint16) iter.Seq2[uint16, uint16] {
+	return func(yield func(first, last uint16) bool) {
+		k, err := r.check()
+		if err != nil || uint64(psid)>>k != 0 {
+			return
+		}
+
+		a := r.PSIDOffset
+		m := 16 - a - k
+
+		first := uint32(0)
+		if a > 0 {
+			first = 1
+		}
+
+		for i := first; i < 1<<a; i++ {
+			lo := i<<(16-a) | uint32(psid)<<m
+			hi := lo | (1<<m - 1)
+			if !yield(uint16(lo), uint16(hi)) {
+				return
+			}
+		}
+	}
+}
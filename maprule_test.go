@@ -0,0 +1,171 @@
+package extnetip_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+// RFC 7597, appendix A, example 1
+var rfc7597Rule = extnetip.MAPRule{
+	IPv6Prefix: mpp("2001:db8::/40"),
+	IPv4Prefix: mpp("192.0.2.0/24"),
+	EABitsLen:  16,
+	PSIDOffset: 6,
+}
+
+func TestMAPRuleCE(t *testing.T) {
+	t.Parallel()
+	r := rfc7597Rule
+
+	if k := r.PSIDLen(); k != 8 {
+		t.Errorf("PSIDLen, got: %d, want: 8", k)
+	}
+
+	ipv4, psid, err := r.CE(mpp("2001:db8:12:3400::/56"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4 != mpa("192.0.2.18") || psid != 0x34 {
+		t.Errorf("CE, got: %s, %#x, want: 192.0.2.18, 0x34", ipv4, psid)
+	}
+
+	pfx, err := r.EndUserPrefix(ipv4, psid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pfx != mpp("2001:db8:12:3400::/56") {
+		t.Errorf("EndUserPrefix, got: %s, want: 2001:db8:12:3400::/56", pfx)
+	}
+
+	// a /64 of the End-user prefix
+	mapAddr, err := r.MAPAddr(mpp("2001:db8:12:34ff::/64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapAddr != mpa("2001:db8:12:3400:0:c000:212:34") {
+		t.Errorf("MAPAddr, got: %s, want: 2001:db8:12:3400:0:c000:212:34", mapAddr)
+	}
+
+	// the MAP address decodes to the same CE
+	ipv4, psid, err = r.CE(netip.PrefixFrom(mapAddr, 128))
+	if err != nil || ipv4 != mpa("192.0.2.18") || psid != 0x34 {
+		t.Errorf("CE(MAP address), got: %s, %#x, %v", ipv4, psid, err)
+	}
+}
+
+func TestMAPRulePorts(t *testing.T) {
+	t.Parallel()
+	r := rfc7597Rule
+
+	var ranges [][2]uint16
+	for first, last := range r.Ports(0x34) {
+		ranges = append(ranges, [2]uint16{first, last})
+	}
+
+	if len(ranges) != 63 {
+		t.Fatalf("Ports, got %d ranges, want: 63", len(ranges))
+	}
+	if ranges[0] != [2]uint16{1232, 1235} || ranges[1] != [2]uint16{2256, 2259} || ranges[62] != [2]uint16{64720, 64723} {
+		t.Errorf("Ports, got: %v ... %v", ranges[:2], ranges[62])
+	}
+
+	for _, rg := range ranges {
+		for port := rg[0]; ; port++ {
+			if psid, ok := r.PSID(port); !ok || psid != 0x34 {
+				t.Fatalf("PSID(%d), got: %#x, %v, want: 0x34", port, psid, ok)
+			}
+			if port == rg[1] {
+				break
+			}
+		}
+	}
+
+	if _, ok := r.PSID(1023); ok {
+		t.Errorf("PSID(1023), got: ok, want: excluded system port")
+	}
+	for range r.Ports(0x100) {
+		t.Errorf("Ports(0x100), got ranges for a PSID exceeding the PSID length")
+		break
+	}
+
+	// no PSID offset, one contiguous range
+	r.PSIDOffset = 0
+	n := 0
+	for first, last := range r.Ports(0xff) {
+		if first != 0xff00 || last != 0xffff {
+			t.Errorf("Ports without offset, got: %d-%d, want: 65280-65535", first, last)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("Ports without offset, got %d ranges, want: 1", n)
+	}
+}
+
+func TestMAPRuleNoSharing(t *testing.T) {
+	t.Parallel()
+
+	// EA bits are just the IPv4 suffix, no port sharing
+	r := extnetip.MAPRule{
+		IPv6Prefix: mpp("2001:db8:ff00::/40"),
+		IPv4Prefix: mpp("198.51.100.0/24"),
+		EABitsLen:  8,
+	}
+
+	pfx, err := r.EndUserPrefix(mpa("198.51.100.7"), 0)
+	if err != nil || pfx != mpp("2001:db8:ff07::/48") {
+		t.Errorf("EndUserPrefix, got: %s, %v, want: 2001:db8:ff07::/48", pfx, err)
+	}
+
+	n := 0
+	for first, last := range r.Ports(0) {
+		if first != 0 || last != 0xffff {
+			t.Errorf("Ports, got: %d-%d, want: 0-65535", first, last)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("Ports, got %d ranges, want: 1", n)
+	}
+}
+
+func TestMAPRuleErrors(t *testing.T) {
+	t.Parallel()
+	invalid := []extnetip.MAPRule{
+		{},
+		{IPv6Prefix: mpp("2001:db8::/40"), IPv4Prefix: mpp("2001:db8::/24"), EABitsLen: 16},
+		{IPv6Prefix: mpp("2001:db8::/40"), IPv4Prefix: mpp("192.0.2.0/24"), EABitsLen: 25},                // exceeds /64
+		{IPv6Prefix: mpp("2001:db8::/40"), IPv4Prefix: mpp("192.0.2.0/24"), EABitsLen: 4},                 // shorter than IPv4 suffix
+		{IPv6Prefix: mpp("2001:db8::/40"), IPv4Prefix: mpp("192.0.2.0/24"), EABitsLen: 16, PSIDOffset: 9}, // exceeds 16 port bits
+	}
+
+	for _, r := range invalid {
+		if err := r.Validate(); !errors.Is(err, extnetip.ErrInvalidRule) {
+			t.Errorf("Validate(%+v), got: %v, want: %v", r, err, extnetip.ErrInvalidRule)
+		}
+		if _, _, err := r.CE(mpp("2001:db8:12:3400::/56")); !errors.Is(err, extnetip.ErrInvalidRule) {
+			t.Errorf("CE(%+v), got: %v, want: %v", r, err, extnetip.ErrInvalidRule)
+		}
+	}
+
+	r := rfc7597Rule
+	if err := r.Validate(); err != nil {
+		t.Errorf("Validate, unexpected error: %v", err)
+	}
+
+	if _, err := r.EndUserPrefix(mpa("198.51.100.1"), 0); !errors.Is(err, extnetip.ErrInvalidAddr) {
+		t.Errorf("EndUserPrefix outside, got: %v, want: %v", err, extnetip.ErrInvalidAddr)
+	}
+	if _, err := r.EndUserPrefix(mpa("192.0.2.18"), 0x100); !errors.Is(err, extnetip.ErrInvalidAddr) {
+		t.Errorf("EndUserPrefix PSID, got: %v, want: %v", err, extnetip.ErrInvalidAddr)
+	}
+
+	for _, pfx := range []netip.Prefix{{}, mpp("2001:db8:12::/48"), mpp("2001:db9:12:3400::/56")} {
+		if _, _, err := r.CE(pfx); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+			t.Errorf("CE(%s), got: %v, want: %v", pfx, err, extnetip.ErrInvalidPrefix)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package extnetip
+
+import (
+	"container/heap"
+	"iter"
+	"net/netip"
+)
+
+// MergeSorted returns an iterator over the k-way merge of the
+// prefix iterators seqs, each already sorted in CIDR order,
+// see [ComparePrefix].
+//
+// The merge is lazy, duplicates and prefixes covered by an already
+// yielded prefix are dropped on the fly, the memory consumption is
+// bounded by the number of input iterators.
+//
+// Invalid prefixes are skipped, all other prefixes are yielded
+// in canonical form. If an input iterator is not sorted, the output
+// is neither guaranteed to be sorted nor free of covered prefixes.
+func MergeSorted(seqs ...iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix] {
+	return func(yield func(netip.Prefix) bool) {
+		h := make(mergeHeap, 0, len(seqs))
+
+		for _, seq := range seqs {
+			next, stop := iter.Pull(seq)
+			defer stop()
+
+			src := &mergeSource{next: next}
+			if src.advance() {
+				h = append(h, src)
+			}
+		}
+		heap.Init(&h)
+
+		var last netip.Prefix
+		for len(h) > 0 {
+			src := h[0]
+			pfx := src.head
+
+			if src.advance() {
+				heap.Fix(&h, 0)
+			} else {
+				heap.Pop(&h)
+			}
+
+			// duplicate or covered by the last yielded prefix
+			if last.IsValid() && last.Bits() <= pfx.Bits() && last.Contains(pfx.Addr()) {
+				continue
+			}
+
+			if !yield(pfx) {
+				return
+			}
+			last = pfx
+		}
+	}
+}
+
+// mergeSource is a pulled input iterator for the k-way merge,
+// the head is the next prefix to merge.
+type mergeSource struct {
+	next func() (netip.Prefix, bool)
+	head netip.Prefix
+}
+
+// advance pulls the next valid prefix into head,
+// it returns false if the source is exhausted.
+func (s *mergeSource) advance() bool {
+	for {
+		pfx, ok := s.next()
+		if !ok {
+			return false
+		}
+		if pfx.IsValid() {
+			s.head = pfx.Masked()
+			return true
+		}
+	}
+}
+
+// mergeHeap is a min-heap of merge sources, ordered by their head prefixes.
+type mergeHeap []*mergeSource
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return ComparePrefix(h[i].head, h[j].head) < 0 }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)        { *h = append(*h, x.(*mergeSource)) }
+
+func (h *mergeHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,94 @@
+package extnetip_test
+
+import (
+	"iter"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestMergeSorted(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		in   [][]netip.Prefix
+		want []netip.Prefix
+	}{
+		{"no input", nil, nil},
+		{"empty inputs", [][]netip.Prefix{nil, nil}, nil},
+		{
+			"single input",
+			[][]netip.Prefix{pfxSlice("10.0.0.0/8", "10.1.0.0/16", "11.0.0.0/8")},
+			pfxSlice("10.0.0.0/8", "11.0.0.0/8"),
+		},
+		{
+			"duplicates",
+			[][]netip.Prefix{
+				pfxSlice("10.0.0.0/24", "10.0.1.0/24"),
+				pfxSlice("10.0.0.0/24", "10.0.2.0/24"),
+				pfxSlice("10.0.1.0/24"),
+			},
+			pfxSlice("10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24"),
+		},
+		{
+			"covered more-specifics",
+			[][]netip.Prefix{
+				pfxSlice("10.0.0.0/24", "192.168.0.0/16"),
+				pfxSlice("10.0.0.0/8", "172.16.0.0/12"),
+				pfxSlice("10.1.2.0/24", "172.16.1.0/24", "192.168.1.0/24"),
+			},
+			pfxSlice("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"),
+		},
+		{
+			"mixed versions",
+			[][]netip.Prefix{
+				pfxSlice("10.0.0.0/8", "2001:db8::/32"),
+				pfxSlice("::/0"),
+				pfxSlice("0.0.0.0/1", "2001:db8:1::/48"),
+			},
+			pfxSlice("0.0.0.0/1", "::/0"),
+		},
+		{
+			"invalid and non-canonical",
+			[][]netip.Prefix{
+				{netip.Prefix{}, mpp("10.1.2.3/8")},
+				{mpp("10.0.0.0/8"), netip.Prefix{}},
+			},
+			pfxSlice("10.0.0.0/8"),
+		},
+	}
+
+	for _, tt := range tests {
+		var seqs []iter.Seq[netip.Prefix]
+		for _, in := range tt.in {
+			seqs = append(seqs, slices.Values(in))
+		}
+
+		got := slices.Collect(extnetip.MergeSorted(seqs...))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("MergeSorted(%s), got: %v, want: %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeSortedBreak(t *testing.T) {
+	t.Parallel()
+	seq := extnetip.MergeSorted(
+		slices.Values(pfxSlice("10.0.0.0/8", "12.0.0.0/8")),
+		slices.Values(pfxSlice("11.0.0.0/8", "13.0.0.0/8")),
+	)
+
+	var got []netip.Prefix
+	for pfx := range seq {
+		got = append(got, pfx)
+		if len(got) == 2 {
+			break
+		}
+	}
+
+	if want := pfxSlice("10.0.0.0/8", "11.0.0.0/8"); !slices.Equal(got, want) {
+		t.Errorf("MergeSorted with break, got: %v, want: %v", got, want)
+	}
+}
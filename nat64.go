@@ -0,0 +1,180 @@
+package extnetip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// ErrInvalidAddr is returned for invalid addresses or addresses
+// of the wrong IP version.
+var ErrInvalidAddr = errors.New("extnetip: invalid address")
+
+// RFC 6052 reserves the bits 64 to 71 of IPv4-embedded IPv6 addresses,
+// the u-octet, it must be zero.
+const (
+	uOctetOff = 64
+	uOctetLen = 8
+)
+
+// checkNAT64Prefix returns an error if prefix is not a valid
+// RFC 6052 prefix, an IPv6 prefix with the length 32, 40, 48, 56, 64 or 96.
+func checkNAT64Prefix(prefix netip.Prefix) error {
+	if !prefix.IsValid() || !prefix.Addr().Is6() {
+		return fmt.Errorf("%w: NAT64 prefix %s", ErrInvalidPrefix, prefix)
+	}
+	switch prefix.Bits() {
+	case 32, 40, 48, 56, 64, 96:
+		return nil
+	}
+	return fmt.Errorf("%w: NAT64 prefix %s, length must be 32, 40, 48, 56, 64 or 96", ErrInvalidPrefix, prefix)
+}
+
+// embed4 returns u with the IPv4 address v4 embedded after the prefix
+// length pl, skipping the u-octet, see RFC 6052 section 2.2.
+func embed4(u uint128, pl int, v4 uint32) uint128 {
+	if pl == 96 {
+		return u.setField(96, 32, uint64(v4))
+	}
+
+	// split by the u-octet
+	n1 := max(uOctetOff-pl, 0)
+	n2 := 32 - n1
+
+	u = u.setField(pl, n1, uint64(v4)>>n2)
+	u = u.setField(uOctetOff, uOctetLen, 0)
+	return u.setField(uOctetOff+uOctetLen, n2, uint64(v4))
+}
+
+// extract4 returns the IPv4 address embedded in u after the prefix
+// length pl, the inverse of embed4.
+func extract4(u uint128, pl int) uint32 {
+	if pl == 96 {
+		return uint32(u.field(96, 32))
+	}
+
+	n1 := max(uOctetOff-pl, 0)
+	n2 := 32 - n1
+
+	return uint32(u.field(pl, n1)<<n2 | u.field(uOctetOff+uOctetLen, n2))
+}
+
+// addr4ToUint32 returns the IPv4 address as uint32.
+func addr4ToUint32(ip netip.Addr) uint32 {
+	a4 := ip.As4()
+	return binary.BigEndian.Uint32(a4[:])
+}
+
+// uint32ToAddr4 returns the uint32 as IPv4 address.
+func uint32ToAddr4(v uint32) netip.Addr {
+	var a4 [4]byte
+	binary.BigEndian.PutUint32(a4[:], v)
+	return netip.AddrFrom4(a4)
+}
+
+// EmbedIPv4 returns the IPv4-embedded IPv6 address for v4 with the
+// NAT64 prefix, as used by NAT64 and DNS64, see RFC 6052.
+//
+// The prefix length must be 32, 40, 48, 56, 64 or 96, e.g. the
+// well-known prefix 64:ff9b::/96. The u-octet (bits 64 to 71) and
+// the suffix are set to zero.
+//
+// It returns an error wrapping [ErrInvalidPrefix] for an invalid
+// prefix, or [ErrInvalidAddr] if v4 is not an IPv4 address.
+func EmbedIPv4(prefix netip.Prefix, v4 netip.Addr) (netip.Addr, error) {
+	if err := checkNAT64Prefix(prefix); err != nil {
+		return netip.Addr{}, err
+	}
+	if !v4.Is4() {
+		return netip.Addr{}, fmt.Errorf("%w: %s is not IPv4", ErrInvalidAddr, v4)
+	}
+
+	u := unwrap(prefix.Masked().Addr()).ip
+	u = embed4(u, prefix.Bits(), addr4ToUint32(v4))
+
+	return wrap(fromUint128(u, false)), nil
+}
+
+// ExtractIPv4 returns the IPv4 address embedded in the IPv6 address v6
+// with the NAT64 prefix, the inverse of [EmbedIPv4].
+//
+// The u-octet and the suffix are ignored. It returns an error wrapping
+// [ErrInvalidPrefix] for an invalid prefix, or [ErrInvalidAddr] if v6 is
+// not within the prefix.
+func ExtractIPv4(prefix netip.Prefix, v6 netip.Addr) (netip.Addr, error) {
+	if err := checkNAT64Prefix(prefix); err != nil {
+		return netip.Addr{}, err
+	}
+	if !v6.Is6() || !prefix.Contains(v6.WithZone("")) {
+		return netip.Addr{}, fmt.Errorf("%w: %s is not in %s", ErrInvalidAddr, v6, prefix)
+	}
+
+	return uint32ToAddr4(extract4(unwrap(v6).ip, prefix.Bits())), nil
+}
+
+// EmbedIPv4Prefix returns the IPv6 prefix covering all IPv4-embedded
+// addresses of the IPv4 prefix v4 with the NAT64 prefix, see [EmbedIPv4].
+//
+// If the IPv4 prefix bits extend beyond bit 63, the u-octet is
+// included in the IPv6 prefix length, e.g. 10.1.2.0/24 with
+// 2001:db8::/48 is 2001:db8:0:a01:2::/80.
+func EmbedIPv4Prefix(prefix, v4 netip.Prefix) (netip.Prefix, error) {
+	if !v4.IsValid() || !v4.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("%w: %s is not IPv4", ErrInvalidPrefix, v4)
+	}
+
+	ip, err := EmbedIPv4(prefix, v4.Masked().Addr())
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	bits := prefix.Bits() + v4.Bits()
+	if prefix.Bits() <= uOctetOff && bits > uOctetOff {
+		bits += uOctetLen
+	}
+
+	return netip.PrefixFrom(ip, bits), nil
+}
+
+// ExtractIPv4Prefix returns the IPv4 prefix of the addresses embedded in
+// the IPv6 prefix v6 with the NAT64 prefix, the inverse of [EmbedIPv4Prefix].
+//
+// Prefix bits of v6 in the u-octet or the suffix are ignored. It returns an
+// error wrapping [ErrInvalidPrefix] if v6 is not covered by the NAT64 prefix.
+func ExtractIPv4Prefix(prefix, v6 netip.Prefix) (netip.Prefix, error) {
+	if err := checkNAT64Prefix(prefix); err != nil {
+		return netip.Prefix{}, err
+	}
+	if !v6.IsValid() || !v6.Addr().Is6() || v6.Bits() < prefix.Bits() || !prefix.Contains(v6.Addr()) {
+		return netip.Prefix{}, fmt.Errorf("%w: %s is not in %s", ErrInvalidPrefix, v6, prefix)
+	}
+
+	pl := prefix.Bits()
+	bits := v6.Bits() - pl
+	if pl <= uOctetOff && v6.Bits() > uOctetOff {
+		bits = max(uOctetOff-pl, bits-uOctetLen)
+	}
+	bits = min(bits, 32)
+
+	v4 := uint32ToAddr4(extract4(unwrap(v6.Masked().Addr()).ip, pl))
+	return netip.PrefixFrom(v4, bits).Masked(), nil
+}
+
+// EmbedIPv4Range returns the IPv6 range of the IPv4-embedded addresses
+// for the inclusive IPv4 range [first, last] with the NAT64 prefix,
+// the embedding preserves the address order, see [EmbedIPv4].
+//
+// It returns an error wrapping [ErrInvalidRange] if the addresses are
+// not IPv4 or first > last.
+func EmbedIPv4Range(prefix netip.Prefix, first, last netip.Addr) (first6, last6 netip.Addr, err error) {
+	if !first.Is4() || !last.Is4() || last.Less(first) {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("%w: %s-%s", ErrInvalidRange, first, last)
+	}
+
+	if first6, err = EmbedIPv4(prefix, first); err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	last6, err = EmbedIPv4(prefix, last)
+	return
+}
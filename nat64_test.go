@@ -0,0 +1,160 @@
+package extnetip_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+// RFC 6052, section 2.4, examples for 192.0.2.33
+var nat64Tests = []struct {
+	prefix netip.Prefix
+	v6     netip.Addr
+}{
+	{mpp("2001:db8::/32"), mpa("2001:db8:c000:221::")},
+	{mpp("2001:db8:100::/40"), mpa("2001:db8:1c0:2:21::")},
+	{mpp("2001:db8:122::/48"), mpa("2001:db8:122:c000:2:2100::")},
+	{mpp("2001:db8:122:300::/56"), mpa("2001:db8:122:3c0:0:221::")},
+	{mpp("2001:db8:122:344::/64"), mpa("2001:db8:122:344:c0:2:2100:0")},
+	{mpp("2001:db8:122:344::/96"), mpa("2001:db8:122:344::192.0.2.33")},
+	{mpp("64:ff9b::/96"), mpa("64:ff9b::192.0.2.33")},
+}
+
+func TestEmbedIPv4(t *testing.T) {
+	t.Parallel()
+	v4 := mpa("192.0.2.33")
+
+	for _, tt := range nat64Tests {
+		got, err := extnetip.EmbedIPv4(tt.prefix, v4)
+		if err != nil {
+			t.Fatalf("EmbedIPv4(%s), unexpected error: %v", tt.prefix, err)
+		}
+		if got != tt.v6 {
+			t.Errorf("EmbedIPv4(%s, %s), got: %s, want: %s", tt.prefix, v4, got, tt.v6)
+		}
+
+		back, err := extnetip.ExtractIPv4(tt.prefix, tt.v6)
+		if err != nil {
+			t.Fatalf("ExtractIPv4(%s), unexpected error: %v", tt.prefix, err)
+		}
+		if back != v4 {
+			t.Errorf("ExtractIPv4(%s, %s), got: %s, want: %s", tt.prefix, tt.v6, back, v4)
+		}
+	}
+}
+
+func TestEmbedIPv4Errors(t *testing.T) {
+	t.Parallel()
+	for _, pfx := range []netip.Prefix{{}, mpp("10.0.0.0/8"), mpp("2001:db8::/33"), mpp("2001:db8::/128")} {
+		if _, err := extnetip.EmbedIPv4(pfx, mpa("192.0.2.1")); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+			t.Errorf("EmbedIPv4(%s), got: %v, want: %v", pfx, err, extnetip.ErrInvalidPrefix)
+		}
+	}
+
+	for _, ip := range []netip.Addr{{}, mpa("::1"), mpa("::ffff:192.0.2.1")} {
+		if _, err := extnetip.EmbedIPv4(mpp("64:ff9b::/96"), ip); !errors.Is(err, extnetip.ErrInvalidAddr) {
+			t.Errorf("EmbedIPv4(%s), got: %v, want: %v", ip, err, extnetip.ErrInvalidAddr)
+		}
+	}
+
+	for _, ip := range []netip.Addr{{}, mpa("192.0.2.1"), mpa("2001:db8::1")} {
+		if _, err := extnetip.ExtractIPv4(mpp("64:ff9b::/96"), ip); !errors.Is(err, extnetip.ErrInvalidAddr) {
+			t.Errorf("ExtractIPv4(%s), got: %v, want: %v", ip, err, extnetip.ErrInvalidAddr)
+		}
+	}
+}
+
+func TestEmbedIPv4Random(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	for range 1000 {
+		tt := nat64Tests[prng.IntN(len(nat64Tests))]
+
+		var a4 [4]byte
+		for i := range a4 {
+			a4[i] = byte(prng.UintN(256))
+		}
+		v4 := netip.AddrFrom4(a4)
+
+		v6, err := extnetip.EmbedIPv4(tt.prefix, v4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the u-octet is zero
+		if b := v6.As16()[8]; b != 0 {
+			t.Fatalf("EmbedIPv4(%s, %s) = %s, u-octet: %d", tt.prefix, v4, v6, b)
+		}
+
+		if back, _ := extnetip.ExtractIPv4(tt.prefix, v6); back != v4 {
+			t.Fatalf("ExtractIPv4(%s, %s), got: %s, want: %s", tt.prefix, v6, back, v4)
+		}
+	}
+}
+
+func TestEmbedIPv4Prefix(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		prefix netip.Prefix
+		v4     netip.Prefix
+		want   netip.Prefix
+	}{
+		{mpp("64:ff9b::/96"), mpp("192.0.2.0/24"), mpp("64:ff9b::c000:200/120")},
+		{mpp("2001:db8::/32"), mpp("10.0.0.0/8"), mpp("2001:db8:a00::/40")},
+		{mpp("2001:db8::/48"), mpp("10.1.0.0/16"), mpp("2001:db8:0:a01::/64")},
+		{mpp("2001:db8::/48"), mpp("10.1.2.0/24"), mpp("2001:db8:0:a01:2::/80")},
+		{mpp("2001:db8::/56"), mpp("10.1.2.3/32"), mpp("2001:db8:0:a:1:203::/96")},
+		{mpp("2001:db8::/64"), mpp("10.1.2.3/30"), mpp("2001:db8::a:102:0:0/102")},
+		{mpp("2001:db8::/64"), mpp("0.0.0.0/0"), mpp("2001:db8::/64")},
+	}
+
+	for _, tt := range tests {
+		got, err := extnetip.EmbedIPv4Prefix(tt.prefix, tt.v4)
+		if err != nil {
+			t.Fatalf("EmbedIPv4Prefix(%s, %s), unexpected error: %v", tt.prefix, tt.v4, err)
+		}
+		if got != tt.want {
+			t.Errorf("EmbedIPv4Prefix(%s, %s), got: %s, want: %s", tt.prefix, tt.v4, got, tt.want)
+		}
+
+		back, err := extnetip.ExtractIPv4Prefix(tt.prefix, got)
+		if err != nil {
+			t.Fatalf("ExtractIPv4Prefix(%s, %s), unexpected error: %v", tt.prefix, got, err)
+		}
+		if back != tt.v4.Masked() {
+			t.Errorf("ExtractIPv4Prefix(%s, %s), got: %s, want: %s", tt.prefix, got, back, tt.v4.Masked())
+		}
+	}
+
+	// prefix bits in the u-octet are ignored
+	got, err := extnetip.ExtractIPv4Prefix(mpp("2001:db8::/48"), mpp("2001:db8:0:a01::/68"))
+	if err != nil || got != mpp("10.1.0.0/16") {
+		t.Errorf("ExtractIPv4Prefix u-octet, got: %s, %v, want: %s", got, err, mpp("10.1.0.0/16"))
+	}
+
+	if _, err := extnetip.ExtractIPv4Prefix(mpp("2001:db8::/48"), mpp("2001:db8::/40")); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("ExtractIPv4Prefix shorter, got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+	if _, err := extnetip.EmbedIPv4Prefix(mpp("2001:db8::/48"), mpp("2001:db8::/64")); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("EmbedIPv4Prefix IPv6, got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+}
+
+func TestEmbedIPv4Range(t *testing.T) {
+	t.Parallel()
+	first, last, err := extnetip.EmbedIPv4Range(mpp("2001:db8::/40"), mpa("10.0.0.5"), mpa("10.0.1.250"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != mpa("2001:db8:a:0:5::") || last != mpa("2001:db8:a:1:fa::") {
+		t.Errorf("EmbedIPv4Range, got: %s-%s", first, last)
+	}
+
+	if _, _, err := extnetip.EmbedIPv4Range(mpp("2001:db8::/40"), mpa("10.0.0.5"), mpa("10.0.0.4")); !errors.Is(err, extnetip.ErrInvalidRange) {
+		t.Errorf("EmbedIPv4Range reversed, got: %v, want: %v", err, extnetip.ErrInvalidRange)
+	}
+}
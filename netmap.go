@@ -0,0 +1,224 @@
+package extnetip
+
+import (
+	"fmt"
+	"iter"
+	"net/netip"
+)
+
+// PrefixMapper is a table of 1:1 NETMAP prefix translations, each mapping
+// translates between two prefixes of equal length and keeps the host bits,
+// e.g. 10.1.2.3 is mapped to 172.20.2.3 with 10.1.0.0/16 -> 172.20.0.0/16.
+//
+// Mappings may be nested, the longest matching prefix is selected, e.g.
+// 10.1.5.0/24 -> 192.168.7.0/24 overrides the mapping above for 10.1.5.0/24.
+// A translation is only valid if the reverse lookup translates it back, so
+// 172.20.5.0/24 is not unmapped, it is shadowed by the override. Shadowed
+// addresses are treated as unmapped in both directions, this keeps the
+// translation 1:1, Unmap(Map(ip)) == ip and Map(Unmap(ip)) == ip.
+//
+// The zero value is an empty mapper ready to use.
+// A PrefixMapper is not safe for concurrent use with writers.
+type PrefixMapper struct {
+	fwd Table[netip.Prefix] // from -> to
+	rev Table[netip.Prefix] // to -> from
+}
+
+// Add adds the mapping from -> to, the prefixes are stored in canonical form.
+//
+// It returns an error wrapping [ErrInvalidPrefix] if a prefix is invalid or
+// the prefixes differ in IP version or length, and [ErrOverlap] if from or
+// to is already mapped, the mappings must be 1:1.
+func (m *PrefixMapper) Add(from, to netip.Prefix) error {
+	if !from.IsValid() || !to.IsValid() || from.Addr().Is4() != to.Addr().Is4() || from.Bits() != to.Bits() {
+		return fmt.Errorf("%w: mapping %s -> %s", ErrInvalidPrefix, from, to)
+	}
+	from, to = from.Masked(), to.Masked()
+
+	if other, ok := m.fwd.Get(from); ok {
+		return fmt.Errorf("%w: %s is already mapped to %s", ErrOverlap, from, other)
+	}
+	if other, ok := m.rev.Get(to); ok {
+		return fmt.Errorf("%w: %s is already mapped from %s", ErrOverlap, to, other)
+	}
+
+	m.fwd.Insert(from, to)
+	m.rev.Insert(to, from)
+	return nil
+}
+
+// Delete removes the mapping for the prefix from, it reports
+// whether the mapping existed.
+func (m *PrefixMapper) Delete(from netip.Prefix) bool {
+	to, ok := m.fwd.Delete(from)
+	if ok {
+		m.rev.Delete(to)
+	}
+	return ok
+}
+
+// Len returns the number of mappings.
+func (m *PrefixMapper) Len() int {
+	return m.fwd.Len()
+}
+
+// All returns an iterator over all mappings from -> to, in CIDR order of from.
+func (m *PrefixMapper) All() iter.Seq2[netip.Prefix, netip.Prefix] {
+	return m.fwd.All2()
+}
+
+// Map translates ip with the longest matching mapping, ok is false if ip
+// is not covered by a mapping or if the translation is shadowed in reverse.
+func (m *PrefixMapper) Map(ip netip.Addr) (netip.Addr, bool) {
+	return mapAddr(&m.fwd, &m.rev, ip)
+}
+
+// Unmap translates ip in the reverse direction, the inverse of [PrefixMapper.Map].
+func (m *PrefixMapper) Unmap(ip netip.Addr) (netip.Addr, bool) {
+	return mapAddr(&m.rev, &m.fwd, ip)
+}
+
+// MapPrefix translates the prefix pfx with the longest mapping covering it,
+// the prefix length is kept.
+//
+// It returns ok=false if pfx is not covered by a mapping, if a more specific
+// mapping within pfx splits it or if the translation is partially shadowed
+// in reverse, see [PrefixMapper.MapRange].
+func (m *PrefixMapper) MapPrefix(pfx netip.Prefix) (netip.Prefix, bool) {
+	return mapPrefix(&m.fwd, &m.rev, pfx)
+}
+
+// UnmapPrefix translates the prefix pfx in the reverse direction,
+// the inverse of [PrefixMapper.MapPrefix].
+func (m *PrefixMapper) UnmapPrefix(pfx netip.Prefix) (netip.Prefix, bool) {
+	return mapPrefix(&m.rev, &m.fwd, pfx)
+}
+
+// MapRange returns an iterator over the translated ranges of the
+// inclusive IP range [first, last], in the order of the source addresses.
+//
+// Each address is translated with its longest matching mapping, unmapped
+// and shadowed addresses are skipped. Adjacent translated ranges are merged.
+func (m *PrefixMapper) MapRange(first, last netip.Addr) iter.Seq2[netip.Addr, netip.Addr] {
+	return mapRange(&m.fwd, &m.rev, first, last)
+}
+
+// UnmapRange returns an iterator over the ranges translated in reverse
+// direction, the inverse of [PrefixMapper.MapRange].
+func (m *PrefixMapper) UnmapRange(first, last netip.Addr) iter.Seq2[netip.Addr, netip.Addr] {
+	return mapRange(&m.rev, &m.fwd, first, last)
+}
+
+// splitBy reports whether pfx contains a more specific mapping in t.
+func splitBy(t *Table[netip.Prefix], pfx netip.Prefix) bool {
+	for sub := range t.Subnets(pfx) {
+		if sub.Bits() > pfx.Bits() {
+			return true
+		}
+	}
+	return false
+}
+
+// mapAddr translates ip with the longest mapping in t, the translation
+// must not be shadowed by a more specific mapping in the inverse table inv.
+func mapAddr(t, inv *Table[netip.Prefix], ip netip.Addr) (netip.Addr, bool) {
+	if !ip.IsValid() {
+		return netip.Addr{}, false
+	}
+
+	to, ok := t.Lookup(ip)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	mapped := graft(to, unwrap(ip))
+
+	if back, ok := inv.Lookup(mapped); !ok || graft(back, unwrap(mapped)) != ip {
+		return netip.Addr{}, false // shadowed
+	}
+	return mapped, true
+}
+
+// translate translates pfx, not split by a more specific mapping in t,
+// it returns ok=false if the translation is split or shadowed in inv.
+func translate(t, inv *Table[netip.Prefix], pfx netip.Prefix) (mapped netip.Prefix, split, ok bool) {
+	to, ok := t.LookupPrefix(pfx)
+	if !ok {
+		return netip.Prefix{}, false, false
+	}
+	mapped = netip.PrefixFrom(graft(to, unwrap(pfx.Addr())), pfx.Bits())
+
+	if splitBy(inv, mapped) {
+		return netip.Prefix{}, true, false
+	}
+	if back, ok := inv.LookupPrefix(mapped); !ok || graft(back, unwrap(mapped.Addr())) != pfx.Addr() {
+		return netip.Prefix{}, false, false // shadowed
+	}
+	return mapped, false, true
+}
+
+// mapPrefix translates pfx with the longest mapping in t covering it.
+func mapPrefix(t, inv *Table[netip.Prefix], pfx netip.Prefix) (netip.Prefix, bool) {
+	if !pfx.IsValid() {
+		return netip.Prefix{}, false
+	}
+	pfx = pfx.Masked()
+
+	if splitBy(t, pfx) {
+		return netip.Prefix{}, false
+	}
+	mapped, _, ok := translate(t, inv, pfx)
+	return mapped, ok
+}
+
+// mapRange translates the range [first, last] with the mappings in t,
+// shadowed parts in inv are skipped.
+func mapRange(t, inv *Table[netip.Prefix], first, last netip.Addr) iter.Seq2[netip.Addr, netip.Addr] {
+	return func(yield func(netip.Addr, netip.Addr) bool) {
+		var curFirst, curLast netip.Addr
+
+		// emit merges adjacent translated ranges
+		emit := func(a, b netip.Addr) bool {
+			if curFirst.IsValid() && curLast.Next() == a {
+				curLast = b
+				return true
+			}
+			if curFirst.IsValid() && !yield(curFirst, curLast) {
+				return false
+			}
+			curFirst, curLast = a, b
+			return true
+		}
+
+		var rec func(pfx netip.Prefix) bool
+		rec = func(pfx netip.Prefix) bool {
+			mapped, split, ok := netip.Prefix{}, splitBy(t, pfx), false
+			if !split {
+				mapped, split, ok = translate(t, inv, pfx)
+			}
+
+			// split by more specific mappings, in t or in inv
+			if split {
+				lower := netip.PrefixFrom(pfx.Addr(), pfx.Bits()+1)
+				_, lowerLast := Range(lower)
+				upper := netip.PrefixFrom(lowerLast.Next(), pfx.Bits()+1)
+				return rec(lower) && rec(upper)
+			}
+			if !ok {
+				return true // unmapped or shadowed
+			}
+
+			a, b := Range(mapped)
+			return emit(a, b)
+		}
+
+		for pfx := range All(first, last) {
+			if !rec(pfx) {
+				return
+			}
+		}
+
+		if curFirst.IsValid() {
+			yield(curFirst, curLast)
+		}
+	}
+}
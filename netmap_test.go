@@ -0,0 +1,255 @@
+package extnetip_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func newTestMapper(t *testing.T, pairs ...string) *extnetip.PrefixMapper {
+	t.Helper()
+	m := new(extnetip.PrefixMapper)
+	for i := 0; i < len(pairs); i += 2 {
+		if err := m.Add(mpp(pairs[i]), mpp(pairs[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestPrefixMapperMap(t *testing.T) {
+	t.Parallel()
+	m := newTestMapper(t,
+		"10.1.0.0/16", "172.20.0.0/16",
+		"10.1.5.0/24", "192.168.7.0/24", // more specific
+		"2001:db8:aaaa::/48", "2001:db8:bbbb::/48",
+	)
+
+	tests := []struct {
+		ip   netip.Addr
+		want netip.Addr
+		ok   bool
+	}{
+		{mpa("10.1.2.3"), mpa("172.20.2.3"), true},
+		{mpa("10.1.255.255"), mpa("172.20.255.255"), true},
+		{mpa("10.1.5.9"), mpa("192.168.7.9"), true},
+		{mpa("2001:db8:aaaa:1::1"), mpa("2001:db8:bbbb:1::1"), true},
+		{mpa("10.2.0.1"), netip.Addr{}, false},
+		{mpa("::ffff:10.1.2.3"), netip.Addr{}, false},
+		{netip.Addr{}, netip.Addr{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := m.Map(tt.ip)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Map(%s), got: %s, %v, want: %s, %v", tt.ip, got, ok, tt.want, tt.ok)
+		}
+		if !ok {
+			continue
+		}
+		if back, ok := m.Unmap(got); !ok || back != tt.ip {
+			t.Errorf("Unmap(%s), got: %s, %v, want: %s", got, back, ok, tt.ip)
+		}
+	}
+}
+
+func TestPrefixMapperShadowed(t *testing.T) {
+	t.Parallel()
+	m := newTestMapper(t,
+		"10.1.0.0/16", "172.20.0.0/16",
+		"10.1.5.0/24", "192.168.7.0/24", // overrides in forward direction
+		"10.0.0.0/8", "20.0.0.0/8",
+		"40.0.0.0/16", "20.5.0.0/16", // overrides in reverse direction
+	)
+
+	// 172.20.5.0/24 would unmap into the override 10.1.5.0/24
+	if got, ok := m.Unmap(mpa("172.20.5.9")); ok {
+		t.Errorf("Unmap(172.20.5.9), got: %s, want: shadowed", got)
+	}
+	if got, ok := m.UnmapPrefix(mpp("172.20.5.0/24")); ok {
+		t.Errorf("UnmapPrefix(172.20.5.0/24), got: %s, want: shadowed", got)
+	}
+	if got, ok := m.UnmapPrefix(mpp("172.20.0.0/16")); ok {
+		t.Errorf("UnmapPrefix(172.20.0.0/16), got: %s, want: partially shadowed", got)
+	}
+
+	// 10.5.0.0/16 would map onto the reverse override 20.5.0.0/16
+	if got, ok := m.Map(mpa("10.5.1.1")); ok {
+		t.Errorf("Map(10.5.1.1), got: %s, want: shadowed", got)
+	}
+	if got, ok := m.Map(mpa("40.0.1.1")); !ok || got != mpa("20.5.1.1") {
+		t.Errorf("Map(40.0.1.1), got: %s, %v, want: 20.5.1.1", got, ok)
+	}
+	if got, ok := m.Unmap(mpa("20.5.1.1")); !ok || got != mpa("40.0.1.1") {
+		t.Errorf("Unmap(20.5.1.1), got: %s, %v, want: 40.0.1.1", got, ok)
+	}
+
+	type rng struct{ first, last netip.Addr }
+	var got []rng
+	for first, last := range m.UnmapRange(mpa("172.20.4.0"), mpa("172.20.6.255")) {
+		got = append(got, rng{first, last})
+	}
+	want := []rng{
+		{mpa("10.1.4.0"), mpa("10.1.4.255")},
+		{mpa("10.1.6.0"), mpa("10.1.6.255")},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("UnmapRange, got: %v, want: %v", got, want)
+	}
+}
+
+func TestPrefixMapperRoundTrip(t *testing.T) {
+	t.Parallel()
+	m := newTestMapper(t,
+		"10.0.0.0/8", "20.0.0.0/8",
+		"10.5.0.0/16", "21.0.0.0/16",
+		"10.5.7.0/24", "20.5.7.0/24",
+		"11.0.0.0/16", "20.1.0.0/16",
+		"12.0.3.0/24", "10.5.3.0/24",
+	)
+
+	prng := rand.New(rand.NewPCG(42, 42))
+	for range 10_000 {
+		ip := netip.AddrFrom4([4]byte{byte(8 + prng.IntN(16)), byte(prng.IntN(6)), byte(prng.IntN(8)), byte(prng.IntN(256))})
+
+		if mapped, ok := m.Map(ip); ok {
+			if back, ok := m.Unmap(mapped); !ok || back != ip {
+				t.Fatalf("Unmap(Map(%s)), got: %s, %v, want: %s", ip, back, ok, ip)
+			}
+		}
+		if unmapped, ok := m.Unmap(ip); ok {
+			if back, ok := m.Map(unmapped); !ok || back != ip {
+				t.Fatalf("Map(Unmap(%s)), got: %s, %v, want: %s", ip, back, ok, ip)
+			}
+		}
+	}
+}
+
+func TestPrefixMapperAdd(t *testing.T) {
+	t.Parallel()
+	m := newTestMapper(t, "10.1.0.0/16", "172.20.0.0/16")
+
+	invalid := [][2]netip.Prefix{
+		{{}, mpp("172.20.0.0/16")},
+		{mpp("10.2.0.0/16"), mpp("172.21.0.0/24")},
+		{mpp("10.2.0.0/16"), mpp("2001:db8::/16")},
+	}
+	for _, p := range invalid {
+		if err := m.Add(p[0], p[1]); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+			t.Errorf("Add(%s, %s), got: %v, want: %v", p[0], p[1], err, extnetip.ErrInvalidPrefix)
+		}
+	}
+
+	if err := m.Add(mpp("10.1.9.9/16"), mpp("172.30.0.0/16")); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("Add same from, got: %v, want: %v", err, extnetip.ErrOverlap)
+	}
+	if err := m.Add(mpp("10.3.0.0/16"), mpp("172.20.0.0/16")); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("Add same to, got: %v, want: %v", err, extnetip.ErrOverlap)
+	}
+
+	// nested overrides are valid in both directions
+	if err := m.Add(mpp("10.1.5.0/24"), mpp("192.168.7.0/24")); err != nil {
+		t.Errorf("Add nested from, unexpected error: %v", err)
+	}
+	if err := m.Add(mpp("40.0.0.0/24"), mpp("172.20.9.0/24")); err != nil {
+		t.Errorf("Add nested to, unexpected error: %v", err)
+	}
+	for _, pfx := range pfxSlice("10.1.5.0/24", "40.0.0.0/24") {
+		if !m.Delete(pfx) {
+			t.Errorf("Delete(%s), got: false", pfx)
+		}
+	}
+
+	if m.Len() != 1 {
+		t.Errorf("Len, got: %d, want: 1", m.Len())
+	}
+	if !m.Delete(mpp("10.1.0.0/16")) || m.Delete(mpp("10.1.0.0/16")) {
+		t.Errorf("Delete, unexpected result")
+	}
+	if _, ok := m.Unmap(mpa("172.20.0.1")); ok {
+		t.Errorf("Unmap after Delete, got: ok")
+	}
+	if err := m.Add(mpp("10.3.0.0/16"), mpp("172.20.0.0/16")); err != nil {
+		t.Errorf("Add after Delete, unexpected error: %v", err)
+	}
+}
+
+func TestPrefixMapperMapPrefix(t *testing.T) {
+	t.Parallel()
+	m := newTestMapper(t,
+		"10.1.0.0/16", "172.20.0.0/16",
+		"10.1.5.0/24", "192.168.7.0/24",
+	)
+
+	tests := []struct {
+		pfx  netip.Prefix
+		want netip.Prefix
+		ok   bool
+	}{
+		{mpp("10.1.0.0/16"), netip.Prefix{}, false}, // split by 10.1.5.0/24
+		{mpp("10.1.0.0/22"), mpp("172.20.0.0/22"), true},
+		{mpp("10.1.5.128/25"), mpp("192.168.7.128/25"), true},
+		{mpp("10.1.5.7/24"), mpp("192.168.7.0/24"), true},
+		{mpp("10.0.0.0/8"), netip.Prefix{}, false},
+		{netip.Prefix{}, netip.Prefix{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := m.MapPrefix(tt.pfx)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("MapPrefix(%s), got: %s, %v, want: %s, %v", tt.pfx, got, ok, tt.want, tt.ok)
+		}
+		if !ok {
+			continue
+		}
+		if back, ok := m.UnmapPrefix(got); !ok || back != tt.pfx.Masked() {
+			t.Errorf("UnmapPrefix(%s), got: %s, %v, want: %s", got, back, ok, tt.pfx.Masked())
+		}
+	}
+}
+
+func TestPrefixMapperMapRange(t *testing.T) {
+	t.Parallel()
+	m := newTestMapper(t,
+		"10.1.0.0/16", "172.20.0.0/16",
+		"10.1.5.0/24", "192.168.7.0/24",
+		"10.2.0.0/16", "172.21.0.0/16", // adjacent source and target
+	)
+
+	type rng struct{ first, last netip.Addr }
+	var got []rng
+	for first, last := range m.MapRange(mpa("10.0.255.0"), mpa("10.2.0.10")) {
+		got = append(got, rng{first, last})
+	}
+
+	want := []rng{
+		{mpa("172.20.0.0"), mpa("172.20.4.255")},
+		{mpa("192.168.7.0"), mpa("192.168.7.255")},
+		{mpa("172.20.6.0"), mpa("172.21.0.10")}, // merged over the mapping boundary
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("MapRange, got: %v, want: %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("MapRange[%d], got: %v, want: %v", i, got[i], want[i])
+		}
+	}
+
+	got = got[:0]
+	for first, last := range m.UnmapRange(mpa("192.168.7.10"), mpa("192.168.7.20")) {
+		got = append(got, rng{first, last})
+	}
+	if len(got) != 1 || got[0] != (rng{mpa("10.1.5.10"), mpa("10.1.5.20")}) {
+		t.Errorf("UnmapRange, got: %v", got)
+	}
+
+	// early stop
+	for range m.MapRange(mpa("10.1.0.0"), mpa("10.2.255.255")) {
+		break
+	}
+}
@@ -0,0 +1,142 @@
+package extnetip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// ErrNotMappable is returned if an address can't be translated.
+var ErrNotMappable = errors.New("extnetip: address not mappable")
+
+// NPTv6 is a stateless IPv6-to-IPv6 network prefix translator with
+// the checksum-neutral mapping of RFC 6296.
+//
+// The prefix is replaced and one 16-bit word of the address is adjusted,
+// so that the one's complement sum of the address, and with it the transport
+// checksums, does not change. For prefixes up to /48 the subnet ID word
+// (bits 48 to 63) is adjusted, for longer prefixes up to /64 the first
+// word of the interface identifier not equal to 0xffff.
+//
+// If the prefixes have different lengths, the shorter one is extended
+// with zero bits to the length of the longer one.
+type NPTv6 struct {
+	internal netip.Prefix
+	external netip.Prefix
+	bits     int
+
+	intKey uint128
+	extKey uint128
+
+	adjOut uint16 // added in the outbound direction
+	adjIn  uint16 // added in the inbound direction
+}
+
+// NewNPTv6 returns a translator between the internal and the external
+// IPv6 prefix, the prefixes must not be longer than /64.
+//
+// It returns an error wrapping [ErrInvalidPrefix] for an invalid prefix.
+func NewNPTv6(internal, external netip.Prefix) (*NPTv6, error) {
+	for _, p := range []netip.Prefix{internal, external} {
+		if !p.IsValid() || !p.Addr().Is6() || p.Addr().Is4In6() || p.Bits() > 64 {
+			return nil, fmt.Errorf("%w: NPTv6 prefix %s, must be IPv6 up to /64", ErrInvalidPrefix, p)
+		}
+	}
+
+	n := &NPTv6{
+		internal: internal.Masked(),
+		external: external.Masked(),
+		bits:     max(internal.Bits(), external.Bits()),
+	}
+	n.intKey = unwrap(n.internal.Addr()).ip
+	n.extKey = unwrap(n.external.Addr()).ip
+
+	sumInt, sumExt := onesSum(n.intKey), onesSum(n.extKey)
+	n.adjOut = onesAdd(sumInt, ^sumExt)
+	n.adjIn = onesAdd(sumExt, ^sumInt)
+
+	return n, nil
+}
+
+// Internal returns the internal prefix.
+func (n *NPTv6) Internal() netip.Prefix {
+	return n.internal
+}
+
+// External returns the external prefix.
+func (n *NPTv6) External() netip.Prefix {
+	return n.external
+}
+
+// Outbound translates the internal address ip to the external address.
+//
+// It returns an error wrapping [ErrNotMappable] if ip is not within the
+// internal prefix, extended to the length of the external prefix, or if
+// there is no word to adjust, e.g. the subnet ID 0xffff for a /48.
+func (n *NPTv6) Outbound(ip netip.Addr) (netip.Addr, error) {
+	return n.translate(ip, n.intKey, n.extKey, n.adjOut)
+}
+
+// Inbound translates the external address ip to the internal address,
+// the inverse of [NPTv6.Outbound].
+//
+// It returns an error wrapping [ErrNotMappable] if ip is not within the
+// external prefix, extended to the length of the internal prefix, or if
+// there is no word to adjust.
+func (n *NPTv6) Inbound(ip netip.Addr) (netip.Addr, error) {
+	return n.translate(ip, n.extKey, n.intKey, n.adjIn)
+}
+
+// translate replaces the prefix from with the prefix to and
+// adds the adjustment adj to the adjustment word.
+func (n *NPTv6) translate(ip netip.Addr, from, to uint128, adj uint16) (netip.Addr, error) {
+	u, ok := unwrap6(ip)
+	mask := mask6(n.bits)
+	if !ok || u.and(mask) != from {
+		return netip.Addr{}, fmt.Errorf("%w: %s is not in %s", ErrNotMappable, ip, netip.PrefixFrom(wrap(fromUint128(from, false)), n.bits))
+	}
+
+	off, ok := n.adjustWord(u)
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("%w: %s has no word to adjust", ErrNotMappable, ip)
+	}
+
+	u = u.and(mask.not()).or(to)
+
+	w := onesAdd(uint16(u.field(off, 16)), adj)
+	if w == 0xffff {
+		w = 0 // the equivalent zero, 0xffff is never mapped
+	}
+	u = u.setField(off, 16, uint64(w))
+
+	return wrap(fromUint128(u, false)), nil
+}
+
+// adjustWord returns the bit offset of the 16-bit word to adjust in u,
+// ok is false if the word(s) are all 0xffff, RFC 6296 section 3.
+func (n *NPTv6) adjustWord(u uint128) (off int, ok bool) {
+	if n.bits <= 48 {
+		return 48, u.field(48, 16) != 0xffff
+	}
+
+	for off = 64; off < 128; off += 16 {
+		if u.field(off, 16) != 0xffff {
+			return off, true
+		}
+	}
+	return 0, false
+}
+
+// onesAdd returns the one's complement sum of a and b.
+func onesAdd(a, b uint16) uint16 {
+	s := uint32(a) + uint32(b)
+	return uint16(s&0xffff + s>>16)
+}
+
+// onesSum returns the one's complement sum of the 16-bit words of u.
+func onesSum(u uint128) (sum uint16) {
+	for off := 0; off < 128; off += 16 {
+		sum = onesAdd(sum, uint16(u.field(off, 16)))
+	}
+	return
+}
@@ -0,0 +1,148 @@
+package extnetip_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+// onesSum16 returns the one's complement sum of the 16-bit words of ip.
+func onesSum16(ip netip.Addr) uint16 {
+	b := ip.As16()
+	var s uint32
+	for i := 0; i < 16; i += 2 {
+		s += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	for s > 0xffff {
+		s = s&0xffff + s>>16
+	}
+	if s == 0xffff {
+		s = 0 // the equivalent zero
+	}
+	return uint16(s)
+}
+
+func TestNPTv6RFCExample(t *testing.T) {
+	t.Parallel()
+
+	// RFC 6296, section 3.1
+	n, err := extnetip.NewNPTv6(mpp("fd01:203:405::/48"), mpp("2001:db8:1::/48"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := n.Outbound(mpa("fd01:203:405:1::1234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mpa("2001:db8:1:d550::1234"); got != want {
+		t.Errorf("Outbound, got: %s, want: %s", got, want)
+	}
+
+	back, err := n.Inbound(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mpa("fd01:203:405:1::1234"); back != want {
+		t.Errorf("Inbound, got: %s, want: %s", back, want)
+	}
+}
+
+func TestNPTv6Random(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	configs := [][2]netip.Prefix{
+		{mpp("fd01:203:405::/48"), mpp("2001:db8:1::/48")},
+		{mpp("fd00:aaaa::/32"), mpp("2001:db8::/32")},
+		{mpp("fd01:203:405:607::/64"), mpp("2001:db8:1:2::/64")},
+		{mpp("fd01:203:400::/40"), mpp("2001:db8:1:200::/56")}, // different lengths
+	}
+
+	for _, cfg := range configs {
+		n, err := extnetip.NewNPTv6(cfg[0], cfg[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for range 1000 {
+			// random address in the internal prefix, extended to the longer length
+			bits := max(cfg[0].Bits(), cfg[1].Bits())
+			b := cfg[0].Addr().As16()
+			for i := bits / 8; i < len(b); i++ {
+				b[i] = byte(prng.UintN(256))
+			}
+			ip := netip.AddrFrom16(b)
+
+			out, err := n.Outbound(ip)
+			if errors.Is(err, extnetip.ErrNotMappable) {
+				continue // 0xffff words, rare
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !n.External().Contains(out) {
+				t.Fatalf("Outbound(%s) = %s, not in %s", ip, out, n.External())
+			}
+			if onesSum16(out) != onesSum16(ip) {
+				t.Fatalf("Outbound(%s) = %s is not checksum-neutral", ip, out)
+			}
+
+			back, err := n.Inbound(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if back != ip {
+				t.Fatalf("Inbound(Outbound(%s)), got: %s", ip, back)
+			}
+		}
+	}
+}
+
+func TestNPTv6Errors(t *testing.T) {
+	t.Parallel()
+	for _, pfx := range []netip.Prefix{{}, mpp("10.0.0.0/8"), mpp("2001:db8::/65"), mpp("::ffff:0:0/96")} {
+		if _, err := extnetip.NewNPTv6(pfx, mpp("2001:db8::/48")); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+			t.Errorf("NewNPTv6(%s), got: %v, want: %v", pfx, err, extnetip.ErrInvalidPrefix)
+		}
+	}
+
+	n, err := extnetip.NewNPTv6(mpp("fd01:203:405::/48"), mpp("2001:db8:1::/48"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unmappable := []netip.Addr{
+		{},
+		mpa("10.0.0.1"),
+		mpa("fd01:203:406::1"),      // not in internal prefix
+		mpa("2001:db8:1::1"),        // external address
+		mpa("fd01:203:405:ffff::1"), // subnet ID 0xffff
+	}
+	for _, ip := range unmappable {
+		if _, err := n.Outbound(ip); !errors.Is(err, extnetip.ErrNotMappable) {
+			t.Errorf("Outbound(%s), got: %v, want: %v", ip, err, extnetip.ErrNotMappable)
+		}
+	}
+
+	n, err = extnetip.NewNPTv6(mpp("fd01:203:405:607::/64"), mpp("2001:db8:1:2::/64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Outbound(mpa("fd01:203:405:607:ffff:ffff:ffff:ffff")); !errors.Is(err, extnetip.ErrNotMappable) {
+		t.Errorf("Outbound all 0xffff IID, got: %v, want: %v", err, extnetip.ErrNotMappable)
+	}
+
+	// the first IID word not equal 0xffff is adjusted
+	out, err := n.Outbound(mpa("fd01:203:405:607:ffff::1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := out.As16(); w[8] != 0xff || w[9] != 0xff {
+		t.Errorf("Outbound, got: %s, want the first IID word unchanged", out)
+	}
+}
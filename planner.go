@@ -0,0 +1,137 @@
+package extnetip
+
+import (
+	"cmp"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"slices"
+)
+
+// SubnetRequest is a named requirement for the VLSM planner, see [PlanSubnets].
+type SubnetRequest struct {
+	// Name identifies the request in the assignments.
+	Name string
+
+	// Hosts is the number of usable host addresses per subnet.
+	Hosts int
+
+	// Count is the number of subnets, zero is treated as one.
+	Count int
+}
+
+// SubnetAssignment is a subnet assigned by the VLSM planner.
+type SubnetAssignment struct {
+	// Name is the name of the request.
+	Name string
+
+	// Index numbers the subnets of a request with Count > 1, starting at 0.
+	Index int
+
+	// Prefix is the assigned subnet.
+	Prefix netip.Prefix
+}
+
+// HostsPrefixLen returns the prefix length of the smallest subnet with
+// at least hosts usable addresses.
+//
+// For IPv4 the network and broadcast addresses are not usable, except
+// for a /31 point-to-point link with two hosts (RFC 3021) and a /32 for a
+// single host. For IPv6 all addresses are counted as usable.
+//
+// It returns ok=false if hosts < 1 or the hosts do not fit in the address family.
+func HostsPrefixLen(hosts int, is4 bool) (bitLen int, ok bool) {
+	if hosts < 1 {
+		return
+	}
+
+	maxBits := 128
+	if is4 {
+		maxBits = 32
+	}
+
+	need := uint64(hosts)
+	if is4 && hosts > 2 {
+		need += 2 // network and broadcast
+	}
+
+	// number of host bits for need addresses, ceil(log2(need))
+	hostBits := bits.Len64(need - 1)
+	if hostBits > maxBits {
+		return
+	}
+
+	return maxBits - hostBits, true
+}
+
+// PlanSubnets computes the smallest prefix for each requested subnet, see
+// [HostsPrefixLen], and packs them largest first into parent without overlap.
+// Requests with the same subnet size keep their order.
+//
+// The assignments are returned in address order. If the requests do not fit,
+// an error wrapping [ErrNoSpace] reports how many addresses are missing.
+func PlanSubnets(parent netip.Prefix, reqs []SubnetRequest) ([]SubnetAssignment, error) {
+	if !parent.IsValid() {
+		return nil, fmt.Errorf("%w: parent %s", ErrInvalidPrefix, parent)
+	}
+	parent = parent.Masked()
+	is4 := parent.Addr().Is4()
+
+	type subnet struct {
+		name  string
+		index int
+		bits  int
+	}
+
+	// the sum of the subnet sizes must not exceed the parent, checked
+	// before the subnets are expanded, a huge Count must not exhaust memory
+	bitLens := make([]int, len(reqs))
+	need := new(big.Int)
+	for i, r := range reqs {
+		bitLen, ok := HostsPrefixLen(r.Hosts, is4)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q with %d hosts", ErrInvalidPrefix, r.Name, r.Hosts)
+		}
+		bitLens[i] = bitLen
+
+		size := new(big.Int).Lsh(big.NewInt(1), uint(parent.Addr().BitLen()-bitLen))
+		need.Add(need, size.Mul(size, big.NewInt(int64(max(r.Count, 1)))))
+	}
+	have := new(big.Int).Lsh(big.NewInt(1), uint(parent.Addr().BitLen()-parent.Bits()))
+
+	if need.Cmp(have) > 0 {
+		missing := new(big.Int).Sub(need, have)
+		return nil, fmt.Errorf("%w: %s has %s addresses, need %s, missing %s",
+			ErrNoSpace, parent, have, need, missing)
+	}
+
+	var subnets []subnet
+	for i, r := range reqs {
+		for n := range max(r.Count, 1) {
+			subnets = append(subnets, subnet{r.Name, n, bitLens[i]})
+		}
+	}
+
+	// largest first, the blocks are then always aligned
+	slices.SortStableFunc(subnets, func(a, b subnet) int { return cmp.Compare(a.bits, b.bits) })
+
+	assigned := make([]SubnetAssignment, 0, len(subnets))
+
+	pos := unwrap(parent.Addr())
+	offset := 0
+	if is4 {
+		offset = 96
+	}
+
+	for _, s := range subnets {
+		pfx := netip.PrefixFrom(wrap(pos), s.bits)
+		assigned = append(assigned, SubnetAssignment{s.name, s.index, pfx})
+
+		// next position, the sizes are descending, no alignment needed
+		size := uint128{0, 1}.lsh(128 - s.bits - offset)
+		pos.ip, _ = pos.ip.add(size)
+	}
+
+	return assigned, nil
+}
@@ -0,0 +1,110 @@
+package extnetip_test
+
+import (
+	"errors"
+	"math"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestHostsPrefixLen(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		hosts int
+		is4   bool
+		bits  int
+		ok    bool
+	}{
+		{0, true, 0, false},
+		{1, true, 32, true},
+		{2, true, 31, true}, // RFC 3021
+		{3, true, 29, true},
+		{6, true, 29, true},
+		{7, true, 28, true},
+		{120, true, 25, true},
+		{254, true, 24, true},
+		{255, true, 23, true},
+		{500, true, 23, true},
+		{1 << 32, true, 0, false},
+		{1, false, 128, true},
+		{2, false, 127, true},
+		{3, false, 126, true},
+		{1 << 62, false, 66, true},
+	}
+
+	for _, tt := range tests {
+		bits, ok := extnetip.HostsPrefixLen(tt.hosts, tt.is4)
+		if bits != tt.bits || ok != tt.ok {
+			t.Errorf("HostsPrefixLen(%d, %v), got: %d, %v, want: %d, %v", tt.hosts, tt.is4, bits, ok, tt.bits, tt.ok)
+		}
+	}
+}
+
+func TestPlanSubnets(t *testing.T) {
+	t.Parallel()
+	reqs := []extnetip.SubnetRequest{
+		{Name: "p2p", Hosts: 2, Count: 3},
+		{Name: "office", Hosts: 500},
+		{Name: "lab", Hosts: 120},
+		{Name: "mgmt", Hosts: 120},
+	}
+
+	got, err := extnetip.PlanSubnets(mpp("10.0.0.0/22"), reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []extnetip.SubnetAssignment{
+		{"office", 0, mpp("10.0.0.0/23")},
+		{"lab", 0, mpp("10.0.2.0/25")},
+		{"mgmt", 0, mpp("10.0.2.128/25")},
+		{"p2p", 0, mpp("10.0.3.0/31")},
+		{"p2p", 1, mpp("10.0.3.2/31")},
+		{"p2p", 2, mpp("10.0.3.4/31")},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("PlanSubnets, got: %v, want: %v", got, want)
+	}
+}
+
+func TestPlanSubnetsErrors(t *testing.T) {
+	t.Parallel()
+	reqs := []extnetip.SubnetRequest{
+		{Name: "office", Hosts: 500},
+		{Name: "lab", Hosts: 120},
+		{Name: "p2p", Hosts: 2, Count: 20},
+	}
+
+	_, err := extnetip.PlanSubnets(mpp("10.0.0.0/24"), reqs)
+	if !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Fatalf("PlanSubnets, got: %v, want: %v", err, extnetip.ErrNoSpace)
+	}
+	if !strings.Contains(err.Error(), "need 680, missing 424") {
+		t.Errorf("PlanSubnets, unexpected error message: %v", err)
+	}
+
+	if _, err := extnetip.PlanSubnets(netip.Prefix{}, reqs); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("PlanSubnets, invalid parent, got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+
+	bad := []extnetip.SubnetRequest{{Name: "empty", Hosts: 0}}
+	if _, err := extnetip.PlanSubnets(mpp("10.0.0.0/24"), bad); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("PlanSubnets, zero hosts, got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+
+	// the space is checked before the subnets are expanded
+	huge := []extnetip.SubnetRequest{{Name: "huge", Hosts: 2, Count: math.MaxInt}}
+	if _, err := extnetip.PlanSubnets(mpp("10.0.0.0/8"), huge); !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("PlanSubnets, huge count, got: %v, want: %v", err, extnetip.ErrNoSpace)
+	}
+
+	// exact fit of the parent
+	full := []extnetip.SubnetRequest{{Name: "all", Hosts: 1 << 62, Count: 1}}
+	if got, err := extnetip.PlanSubnets(mpp("2001:db8::/66"), full); err != nil || got[0].Prefix != mpp("2001:db8::/66") {
+		t.Errorf("PlanSubnets, exact fit, got: %v, %v", got, err)
+	}
+}
@@ -0,0 +1,421 @@
+package extnetip
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotLeased is returned if an owner has no active lease.
+	ErrNotLeased = errors.New("extnetip: no active lease")
+
+	// ErrInvalidTTL is returned for a lease duration <= 0.
+	ErrInvalidTTL = errors.New("extnetip: invalid lease duration")
+)
+
+// Lease is an address leased by an owner until expiry.
+type Lease struct {
+	Addr   netip.Addr
+	Owner  string
+	Expiry time.Time
+}
+
+// Pool leases single addresses from the inclusive IP range [first, last].
+//
+// Addresses are mapped to their offset from the first address in uint128
+// space, this makes the index to address mapping O(1) in both directions.
+// An owner gets its previous address again, if it is still free (sticky).
+// Leases expire by the injected clock, expired leases are reclaimed lazily.
+//
+// A Pool is safe for concurrent use.
+type Pool struct {
+	mu    sync.Mutex
+	first addr
+	last  addr
+	now   func() time.Time
+
+	reserved map[uint128]struct{}
+	leases   map[uint128]*poolLease // by offset
+	owners   map[string]uint128     // last offset of each owner, sticky
+	sticky   map[uint128]string     // reverse of owners
+	next     uint128                // offset to start the search for free addresses
+
+	persister Persister
+}
+
+// poolLease is the lease state of an offset.
+type poolLease struct {
+	owner  string
+	expiry time.Time
+}
+
+// NewPool returns an address pool for the inclusive IP range [first, last].
+// The clock now drives the lease expiry, if nil time.Now is used.
+func NewPool(first, last netip.Addr, now func() time.Time) (*Pool, error) {
+	if !first.IsValid() || !last.IsValid() {
+		return nil, fmt.Errorf("%w: %s-%s", ErrInvalidRange, first, last)
+	}
+
+	a, b := unwrap(first), unwrap(last)
+	if a.is4() != b.is4() || a.ip.compare(b.ip) == 1 {
+		return nil, fmt.Errorf("%w: %s-%s", ErrInvalidRange, first, last)
+	}
+
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Pool{
+		first:    a,
+		last:     b,
+		now:      now,
+		reserved: map[uint128]struct{}{},
+		leases:   map[uint128]*poolLease{},
+		owners:   map[string]uint128{},
+		sticky:   map[uint128]string{},
+	}, nil
+}
+
+// index returns the offset of ip in the pool.
+func (p *Pool) index(ip netip.Addr) (uint128, bool) {
+	if !ip.IsValid() {
+		return uint128{}, false
+	}
+	a := unwrap(ip)
+	if a.is4() != p.first.is4() || a.ip.compare(p.first.ip) < 0 || a.ip.compare(p.last.ip) > 0 {
+		return uint128{}, false
+	}
+	return a.ip.sub(p.first.ip), true
+}
+
+// addr returns the address at offset i in the pool.
+func (p *Pool) addr(i uint128) netip.Addr {
+	ip, _ := p.first.ip.add(i)
+	return wrap(fromUint128(ip, p.first.is4()))
+}
+
+// Reserve excludes ip from leasing, e.g. the gateway or broadcast address.
+//
+// It returns an error wrapping [ErrNotInPool] if ip is outside the range.
+// An active lease of ip is revoked.
+func (p *Pool) Reserve(ip netip.Addr) error {
+	i, ok := p.index(ip)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotInPool, ip)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.persist(Record{Op: RecordReserve, Lease: Lease{Addr: ip}}); err != nil {
+		return err
+	}
+
+	p.reserve(i)
+	return nil
+}
+
+// reserve excludes offset i from leasing and revokes its lease.
+func (p *Pool) reserve(i uint128) {
+	p.reserved[i] = struct{}{}
+	delete(p.leases, i)
+	p.forget(i)
+}
+
+// forget removes the sticky owner of offset i.
+func (p *Pool) forget(i uint128) {
+	if owner, ok := p.sticky[i]; ok {
+		delete(p.sticky, i)
+		delete(p.owners, owner)
+	}
+}
+
+// active returns the unexpired lease at offset i.
+func (p *Pool) active(i uint128, now time.Time) (*poolLease, bool) {
+	l, ok := p.leases[i]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(l.expiry) {
+		delete(p.leases, i) // reclaim expired lease
+		return nil, false
+	}
+	return l, true
+}
+
+// isFree reports whether offset i is neither reserved nor actively leased.
+func (p *Pool) isFree(i uint128, now time.Time) bool {
+	if _, ok := p.reserved[i]; ok {
+		return false
+	}
+	_, ok := p.active(i, now)
+	return !ok
+}
+
+// Lease returns a lease for owner with the duration ttl.
+//
+// An active lease of owner is renewed. Otherwise the previous address of
+// owner is leased again if it is free, else the next free address. Free
+// addresses remembered for other owners are only taken if no other address
+// is available. It returns an error wrapping [ErrNoSpace] if the pool is exhausted
+// and [ErrInvalidTTL] if ttl <= 0.
+func (p *Pool) Lease(owner string, ttl time.Duration) (Lease, error) {
+	if ttl <= 0 {
+		return Lease{}, fmt.Errorf("%w: %s", ErrInvalidTTL, ttl)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+
+	// sticky, the previous address of owner
+	if i, ok := p.owners[owner]; ok {
+		if l, ok := p.active(i, now); ok && l.owner == owner {
+			return p.renew(i, l, now.Add(ttl))
+		}
+		if p.isFree(i, now) {
+			return p.persistAssign(i, owner, now.Add(ttl))
+		}
+	}
+
+	// first try to spare the remembered addresses of other owners
+	i, ok := p.search(now, func(i uint128) bool {
+		_, ok := p.sticky[i]
+		return !ok
+	})
+	if !ok {
+		i, ok = p.search(now, func(uint128) bool { return true })
+	}
+	if !ok {
+		return Lease{}, fmt.Errorf("%w: %s-%s", ErrNoSpace, wrap(p.first), wrap(p.last))
+	}
+
+	return p.persistAssign(i, owner, now.Add(ttl))
+}
+
+// persistAssign persists the lease of offset i and assigns it to owner.
+func (p *Pool) persistAssign(i uint128, owner string, expiry time.Time) (Lease, error) {
+	l := Lease{Addr: p.addr(i), Owner: owner, Expiry: expiry}
+	if err := p.persist(Record{Op: RecordLease, Lease: l}); err != nil {
+		return Lease{}, err
+	}
+	return p.assign(i, owner, expiry), nil
+}
+
+// renew persists the new expiry of the lease l at offset i and sets it.
+func (p *Pool) renew(i uint128, l *poolLease, expiry time.Time) (Lease, error) {
+	lease := Lease{Addr: p.addr(i), Owner: l.owner, Expiry: expiry}
+	if err := p.persist(Record{Op: RecordLease, Lease: lease}); err != nil {
+		return Lease{}, err
+	}
+	l.expiry = expiry
+	return lease, nil
+}
+
+// persist passes the record to the persister, if any.
+func (p *Pool) persist(r Record) error {
+	if p.persister == nil {
+		return nil
+	}
+	return p.persister.Persist(r)
+}
+
+// search returns the next free offset accepted by ok, starting at the search
+// cursor and wrapping around at the end of the pool.
+func (p *Pool) search(now time.Time, ok func(uint128) bool) (uint128, bool) {
+	size := p.last.ip.sub(p.first.ip) // size - 1
+	i := p.next
+
+	for {
+		if p.isFree(i, now) && ok(i) {
+			return i, true
+		}
+
+		if i == size {
+			i = uint128{}
+		} else {
+			i = i.addOne()
+		}
+
+		if i == p.next {
+			return uint128{}, false
+		}
+	}
+}
+
+// assign leases offset i to owner and advances the search cursor.
+func (p *Pool) assign(i uint128, owner string, expiry time.Time) Lease {
+	// forget the previous address of owner and the previous owner of i
+	if prev, ok := p.owners[owner]; ok {
+		if prev != i {
+			delete(p.leases, prev)
+		}
+		p.forget(prev)
+	}
+	p.forget(i)
+
+	l := &poolLease{owner, expiry}
+	p.leases[i] = l
+	p.owners[owner] = i
+	p.sticky[i] = owner
+
+	if i == p.last.ip.sub(p.first.ip) {
+		p.next = uint128{}
+	} else {
+		p.next = i.addOne()
+	}
+
+	return p.lease(i, l)
+}
+
+// lease returns the exported lease for offset i.
+func (p *Pool) lease(i uint128, l *poolLease) Lease {
+	return Lease{Addr: p.addr(i), Owner: l.owner, Expiry: l.expiry}
+}
+
+// Renew extends the active lease of owner by ttl from now.
+//
+// It returns an error wrapping [ErrNotLeased] if owner has no active lease
+// and [ErrInvalidTTL] if ttl <= 0.
+func (p *Pool) Renew(owner string, ttl time.Duration) (Lease, error) {
+	if ttl <= 0 {
+		return Lease{}, fmt.Errorf("%w: %s", ErrInvalidTTL, ttl)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+
+	i, ok := p.owners[owner]
+	if !ok {
+		return Lease{}, fmt.Errorf("%w: %q", ErrNotLeased, owner)
+	}
+
+	l, ok := p.active(i, now)
+	if !ok || l.owner != owner {
+		return Lease{}, fmt.Errorf("%w: %q", ErrNotLeased, owner)
+	}
+
+	return p.renew(i, l, now.Add(ttl))
+}
+
+// Release ends the active lease of owner, the address is still
+// remembered for owner as long as it is not leased to others.
+//
+// It returns an error wrapping [ErrNotLeased] if owner has no active lease.
+func (p *Pool) Release(owner string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i, ok := p.owners[owner]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNotLeased, owner)
+	}
+
+	l, ok := p.active(i, p.now())
+	if !ok || l.owner != owner {
+		return fmt.Errorf("%w: %q", ErrNotLeased, owner)
+	}
+
+	if err := p.persist(Record{Op: RecordUnlease, Lease: Lease{Owner: owner}}); err != nil {
+		return err
+	}
+
+	delete(p.leases, i)
+	return nil
+}
+
+// SetPersister sets the persister for all following state changes,
+// nil disables the persistence, see [Journal].
+//
+// A change is rejected with the error of the persister if it can't be persisted.
+func (p *Pool) SetPersister(ps Persister) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.persister = ps
+}
+
+// Replay applies the lease records, e.g. recovered from a [Journal],
+// other records are ignored. The records are not persisted again.
+//
+// Leases are replayed regardless of their expiry, a later lease of the same
+// address replaces an earlier one. It returns an error wrapping [ErrNotInPool],
+// [ErrOverlap] for a lease of a reserved address or [ErrNotLeased] for the
+// first invalid record, the preceding records remain applied.
+func (p *Pool) Replay(recs iter.Seq[Record]) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for r := range recs {
+		switch r.Op {
+		case RecordLease:
+			i, ok := p.index(r.Lease.Addr)
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrNotInPool, r.Lease.Addr)
+			}
+			if _, ok := p.reserved[i]; ok {
+				return fmt.Errorf("%w: lease of reserved %s", ErrOverlap, r.Lease.Addr)
+			}
+			p.assign(i, r.Lease.Owner, r.Lease.Expiry)
+
+		case RecordUnlease:
+			i, ok := p.owners[r.Lease.Owner]
+			if l, held := p.leases[i]; !ok || !held || l.owner != r.Lease.Owner {
+				return fmt.Errorf("%w: %q", ErrNotLeased, r.Lease.Owner)
+			}
+			delete(p.leases, i)
+
+		case RecordReserve:
+			i, ok := p.index(r.Lease.Addr)
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrNotInPool, r.Lease.Addr)
+			}
+			p.reserve(i)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the active lease of ip.
+func (p *Pool) Lookup(ip netip.Addr) (Lease, bool) {
+	i, ok := p.index(ip)
+	if !ok {
+		return Lease{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.active(i, p.now())
+	if !ok {
+		return Lease{}, false
+	}
+	return p.lease(i, l), true
+}
+
+// Leases returns an iterator over a snapshot of the active leases
+// in ascending address order.
+func (p *Pool) Leases() iter.Seq[Lease] {
+	p.mu.Lock()
+
+	now := p.now()
+	leases := make([]Lease, 0, len(p.leases))
+	for i, l := range p.leases {
+		if now.Before(l.expiry) {
+			leases = append(leases, p.lease(i, l))
+		}
+	}
+
+	p.mu.Unlock()
+
+	slices.SortFunc(leases, func(a, b Lease) int { return compareAddr(a.Addr, b.Addr) })
+	return slices.Values(leases)
+}
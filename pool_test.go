@@ -0,0 +1,246 @@
+package extnetip_test
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gaissmai/extnetip"
+)
+
+// fakeClock is a manually advanced clock for lease expiry tests.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestPool(t *testing.T, first, last string) (*extnetip.Pool, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p, err := extnetip.NewPool(mpa(first), mpa(last), clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, clock
+}
+
+func TestNewPool(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		first netip.Addr
+		last  netip.Addr
+	}{
+		{netip.Addr{}, mpa("10.0.0.1")},
+		{mpa("10.0.0.2"), mpa("10.0.0.1")},
+		{mpa("10.0.0.1"), mpa("::1")},
+	}
+
+	for _, tt := range tests {
+		if _, err := extnetip.NewPool(tt.first, tt.last, nil); !errors.Is(err, extnetip.ErrInvalidRange) {
+			t.Errorf("NewPool(%s, %s), got: %v, want: %v", tt.first, tt.last, err, extnetip.ErrInvalidRange)
+		}
+	}
+}
+
+func TestPoolLease(t *testing.T) {
+	t.Parallel()
+	p, clock := newTestPool(t, "192.168.0.0", "192.168.0.7")
+
+	// gateway and broadcast
+	for _, s := range []string{"192.168.0.0", "192.168.0.1", "192.168.0.7"} {
+		if err := p.Reserve(mpa(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Reserve(mpa("192.168.1.0")); !errors.Is(err, extnetip.ErrNotInPool) {
+		t.Errorf("Reserve outside, got: %v, want: %v", err, extnetip.ErrNotInPool)
+	}
+
+	var got []netip.Addr
+	for _, owner := range []string{"a", "b", "c", "d", "e"} {
+		l, err := p.Lease(owner, time.Hour)
+		if err != nil {
+			t.Fatalf("Lease(%s), unexpected error: %v", owner, err)
+		}
+		if l.Owner != owner || !l.Expiry.Equal(clock.now().Add(time.Hour)) {
+			t.Errorf("Lease(%s), got: %+v", owner, l)
+		}
+		got = append(got, l.Addr)
+	}
+
+	want := []netip.Addr{mpa("192.168.0.2"), mpa("192.168.0.3"), mpa("192.168.0.4"), mpa("192.168.0.5"), mpa("192.168.0.6")}
+	if !slices.Equal(got, want) {
+		t.Errorf("Lease, got: %v, want: %v", got, want)
+	}
+
+	if _, err := p.Lease("f", time.Hour); !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("Lease exhausted, got: %v, want: %v", err, extnetip.ErrNoSpace)
+	}
+
+	// lease of an active owner is renewed
+	clock.advance(30 * time.Minute)
+	l, err := p.Lease("c", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Addr != mpa("192.168.0.4") || !l.Expiry.Equal(clock.now().Add(time.Hour)) {
+		t.Errorf("Lease again, got: %+v", l)
+	}
+
+	if l, ok := p.Lookup(mpa("192.168.0.4")); !ok || l.Owner != "c" {
+		t.Errorf("Lookup, got: %+v, %v", l, ok)
+	}
+	if _, ok := p.Lookup(mpa("192.168.0.1")); ok {
+		t.Errorf("Lookup reserved, got: true, want: false")
+	}
+}
+
+func TestPoolExpiry(t *testing.T) {
+	t.Parallel()
+	p, clock := newTestPool(t, "10.0.0.1", "10.0.0.2")
+
+	if _, err := p.Lease("a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Lease("b", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.advance(time.Minute)
+
+	// the lease of a is expired at its expiry time
+	if _, err := p.Renew("a", time.Minute); !errors.Is(err, extnetip.ErrNotLeased) {
+		t.Errorf("Renew expired, got: %v, want: %v", err, extnetip.ErrNotLeased)
+	}
+
+	l, err := p.Lease("c", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Addr != mpa("10.0.0.1") {
+		t.Errorf("Lease after expiry, got: %s, want: %s", l.Addr, mpa("10.0.0.1"))
+	}
+
+	got := slices.Collect(p.Leases())
+	if len(got) != 2 || got[0].Owner != "c" || got[1].Owner != "b" {
+		t.Errorf("Leases, got: %+v", got)
+	}
+
+	l, err = p.Renew("b", 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Expiry.Equal(clock.now().Add(2 * time.Hour)) {
+		t.Errorf("Renew, got expiry: %s", l.Expiry)
+	}
+}
+
+func TestPoolSticky(t *testing.T) {
+	t.Parallel()
+	p, clock := newTestPool(t, "2001:db8::1", "2001:db8::3")
+
+	addrOf := map[string]netip.Addr{}
+	for _, owner := range []string{"a", "b"} {
+		l, err := p.Lease(owner, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrOf[owner] = l.Addr
+	}
+
+	if err := p.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Release("a"); !errors.Is(err, extnetip.ErrNotLeased) {
+		t.Errorf("Release twice, got: %v, want: %v", err, extnetip.ErrNotLeased)
+	}
+	if err := p.Release("x"); !errors.Is(err, extnetip.ErrNotLeased) {
+		t.Errorf("Release unknown, got: %v, want: %v", err, extnetip.ErrNotLeased)
+	}
+
+	clock.advance(time.Hour)
+
+	// c spares the addresses remembered for a and b
+	l, err := p.Lease("c", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Addr != mpa("2001:db8::3") {
+		t.Errorf("Lease(c), got: %s, want: %s", l.Addr, mpa("2001:db8::3"))
+	}
+
+	// a and b get their previous addresses back, in reverse order
+	for _, owner := range []string{"b", "a"} {
+		l, err := p.Lease(owner, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Addr != addrOf[owner] {
+			t.Errorf("Lease(%s), got: %s, want: %s", owner, l.Addr, addrOf[owner])
+		}
+	}
+
+	// exhausted, remembered addresses are reused
+	clock.advance(time.Hour)
+	for _, owner := range []string{"d", "e", "f"} {
+		if _, err := p.Lease(owner, time.Minute); err != nil {
+			t.Fatalf("Lease(%s), unexpected error: %v", owner, err)
+		}
+	}
+
+	// a lost its address to another owner
+	l, err = p.Lease("a", time.Minute)
+	if !errors.Is(err, extnetip.ErrNoSpace) {
+		t.Errorf("Lease(a), got: %+v, %v, want: %v", l, err, extnetip.ErrNoSpace)
+	}
+}
+
+func TestPoolReserveRevokes(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestPool(t, "10.0.0.1", "10.0.0.2")
+
+	l, err := p.Lease("a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reserve(l.Addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Renew("a", time.Minute); !errors.Is(err, extnetip.ErrNotLeased) {
+		t.Errorf("Renew reserved, got: %v, want: %v", err, extnetip.ErrNotLeased)
+	}
+
+	l, err = p.Lease("a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Addr != mpa("10.0.0.2") {
+		t.Errorf("Lease after Reserve, got: %s, want: %s", l.Addr, mpa("10.0.0.2"))
+	}
+}
+
+func TestPoolInvalidTTL(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestPool(t, "10.0.0.1", "10.0.0.9")
+
+	for _, ttl := range []time.Duration{0, -time.Hour} {
+		if _, err := p.Lease("a", ttl); !errors.Is(err, extnetip.ErrInvalidTTL) {
+			t.Errorf("Lease(%s), got: %v, want: %v", ttl, err, extnetip.ErrInvalidTTL)
+		}
+	}
+	if n := len(slices.Collect(p.Leases())); n != 0 {
+		t.Errorf("Leases, got %d, want: 0", n)
+	}
+
+	if _, err := p.Lease("a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Renew("a", 0); !errors.Is(err, extnetip.ErrInvalidTTL) {
+		t.Errorf("Renew(0), got: %v, want: %v", err, extnetip.ErrInvalidTTL)
+	}
+	if _, ok := p.Lookup(mpa("10.0.0.1")); !ok {
+		t.Errorf("Lookup after rejected Renew, got: not leased")
+	}
+}
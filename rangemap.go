@@ -0,0 +1,218 @@
+package extnetip
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net/netip"
+	"slices"
+)
+
+var (
+	// ErrInvalidRange is returned for invalid IPs, mismatched IP versions or first > last.
+	ErrInvalidRange = errors.New("extnetip: invalid range")
+
+	// ErrOverlap is returned if a range overlaps existing ranges and
+	// the policy forbids overlaps.
+	ErrOverlap = errors.New("extnetip: overlapping ranges")
+)
+
+// Segment is the inclusive IP range [First, Last] carrying a value.
+type Segment[V any] struct {
+	First netip.Addr
+	Last  netip.Addr
+	Value V
+}
+
+// OverlapPolicy defines how a [RangeMap] resolves overlapping ranges.
+type OverlapPolicy uint8
+
+const (
+	// LastWins assigns the overlapping addresses to the last inserted range.
+	LastWins OverlapPolicy = iota
+
+	// MostSpecificWins assigns the overlapping addresses to the smallest range,
+	// for ranges of the same size the last inserted range wins.
+	MostSpecificWins
+
+	// ErrorOnOverlap rejects ranges overlapping existing ranges with [ErrOverlap].
+	ErrorOnOverlap
+)
+
+// RangeMap maps arbitrary IP ranges to values, e.g. for GeoIP or ASN data.
+//
+// The ranges are stored as sorted disjoint segments in uint128 space,
+// overlapping ranges are split according to the [OverlapPolicy].
+// Lookups are done by binary search on the segment starts.
+//
+// The zero value is an empty map with policy LastWins.
+// A RangeMap is not safe for concurrent use with writers.
+type RangeMap[V any] struct {
+	policy  OverlapPolicy
+	entries []rangeEntry[V]
+}
+
+// rangeEntry is a disjoint segment of the range map, span is the
+// size-1 of the inserted range the value originates from.
+type rangeEntry[V any] struct {
+	first addr
+	last  addr
+	span  uint128
+	value V
+}
+
+// NewRangeMap returns an empty range map with the given overlap policy.
+func NewRangeMap[V any](policy OverlapPolicy) *RangeMap[V] {
+	return &RangeMap[V]{policy: policy}
+}
+
+// Len returns the number of disjoint segments in the map.
+func (m *RangeMap[V]) Len() int {
+	return len(m.entries)
+}
+
+// Insert maps the inclusive IP range [first, last] to val.
+//
+// Overlaps with existing ranges are resolved according to the policy of
+// the map, with ErrorOnOverlap the map is unchanged and an error wrapping
+// [ErrOverlap] is returned. For invalid ranges [ErrInvalidRange] is returned.
+func (m *RangeMap[V]) Insert(first, last netip.Addr, val V) error {
+	if !first.IsValid() || !last.IsValid() {
+		return fmt.Errorf("%w: %s-%s", ErrInvalidRange, first, last)
+	}
+
+	a, b := unwrap(first), unwrap(last)
+	if a.is4() != b.is4() || a.ip.compare(b.ip) == 1 {
+		return fmt.Errorf("%w: %s-%s", ErrInvalidRange, first, last)
+	}
+
+	// the overlapped entries are [i, j)
+	i, _ := slices.BinarySearchFunc(m.entries, a, func(e rangeEntry[V], t addr) int {
+		return compareAddrs(e.last, t)
+	})
+	j, _ := slices.BinarySearchFunc(m.entries[i:], b, func(e rangeEntry[V], t addr) int {
+		if compareAddrs(e.first, t) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	j += i
+
+	if i < j && m.policy == ErrorOnOverlap {
+		e := m.entries[i]
+		return fmt.Errorf("%w: %s-%s overlaps %s-%s", ErrOverlap, first, last, wrap(e.first), wrap(e.last))
+	}
+
+	span := b.ip.sub(a.ip)
+	fresh := rangeEntry[V]{a, b, span, val}
+
+	// build the replacement for the overlapped entries
+	repl := make([]rangeEntry[V], 0, 2*(j-i)+2)
+
+	// appendFresh appends a piece of the new range, adjacent pieces are merged
+	lastFresh := false
+	appendFresh := func(first, last addr) {
+		if lastFresh {
+			repl[len(repl)-1].last = last
+			return
+		}
+		e := fresh
+		e.first, e.last = first, last
+		repl = append(repl, e)
+		lastFresh = true
+	}
+
+	// left remainder of an entry starting before the new range
+	if i < j && m.entries[i].first.ip.compare(a.ip) < 0 {
+		left := m.entries[i]
+		left.last = fromUint128(a.ip.subOne(), a.is4())
+		repl = append(repl, left)
+	}
+
+	pos := a
+	done := false
+	for _, e := range m.entries[i:j] {
+		// clip the entry to the new range
+		if e.first.ip.compare(a.ip) < 0 {
+			e.first = a
+		}
+		if e.last.ip.compare(b.ip) > 0 {
+			e.last = b
+		}
+
+		// gap in front of the entry
+		if pos.ip.compare(e.first.ip) < 0 {
+			appendFresh(pos, fromUint128(e.first.ip.subOne(), a.is4()))
+		}
+
+		if m.policy == LastWins || span.compare(e.span) <= 0 {
+			appendFresh(e.first, e.last)
+		} else {
+			repl = append(repl, e)
+			lastFresh = false
+		}
+
+		done = e.last.ip == b.ip
+		pos = fromUint128(e.last.ip.addOne(), a.is4())
+	}
+
+	// the rest of the new range
+	if !done {
+		appendFresh(pos, b)
+	}
+
+	// right remainder of an entry ending after the new range
+	if i < j && m.entries[j-1].last.ip.compare(b.ip) > 0 {
+		right := m.entries[j-1]
+		right.first = fromUint128(b.ip.addOne(), a.is4())
+		repl = append(repl, right)
+	}
+
+	m.entries = slices.Replace(m.entries, i, j, repl...)
+	return nil
+}
+
+// Lookup returns the value of the range containing ip.
+func (m *RangeMap[V]) Lookup(ip netip.Addr) (val V, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
+	a := unwrap(ip)
+
+	i, _ := slices.BinarySearchFunc(m.entries, a, func(e rangeEntry[V], t addr) int {
+		return compareAddrs(e.last, t)
+	})
+	if i < len(m.entries) && compareAddrs(m.entries[i].first, a) <= 0 {
+		return m.entries[i].value, true
+	}
+	return
+}
+
+// All returns an iterator over the disjoint segments of the map in
+// ascending order, IPv4 before IPv6.
+//
+// Overlapping ranges are already resolved, a single inserted range may
+// be split into multiple segments.
+func (m *RangeMap[V]) All() iter.Seq[Segment[V]] {
+	return func(yield func(Segment[V]) bool) {
+		for _, e := range m.entries {
+			if !yield(Segment[V]{wrap(e.first), wrap(e.last), e.value}) {
+				return
+			}
+		}
+	}
+}
+
+// Prefixes returns an iterator over the minimal prefixes of all segments
+// of the map with their values, see [All].
+func (m *RangeMap[V]) Prefixes() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		for _, e := range m.entries {
+			for pfx := range All(wrap(e.first), wrap(e.last)) {
+				if !yield(pfx, e.value) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package extnetip_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestRangeMap(t *testing.T) {
+	t.Parallel()
+	m := extnetip.NewRangeMap[string](extnetip.LastWins)
+
+	if err := m.Insert(mpa("10.0.0.9"), mpa("10.0.0.1"), "wrong order"); !errors.Is(err, extnetip.ErrInvalidRange) {
+		t.Errorf("Insert, wrong order, got: %v, want: %v", err, extnetip.ErrInvalidRange)
+	}
+	if err := m.Insert(mpa("10.0.0.1"), mpa("::1"), "wrong versions"); !errors.Is(err, extnetip.ErrInvalidRange) {
+		t.Errorf("Insert, wrong versions, got: %v, want: %v", err, extnetip.ErrInvalidRange)
+	}
+	if err := m.Insert(netip.Addr{}, mpa("10.0.0.1"), "invalid"); !errors.Is(err, extnetip.ErrInvalidRange) || err == extnetip.ErrInvalidRange {
+		t.Errorf("Insert, invalid, got: %v, want: wrapped %v", err, extnetip.ErrInvalidRange)
+	}
+
+	for _, e := range []struct {
+		first, last string
+		val         string
+	}{
+		{"10.0.0.0", "10.0.0.255", "a"},
+		{"10.0.0.100", "10.0.0.199", "b"},
+		{"2001:db8::", "2001:db8::ffff", "c"},
+		{"::", "::", "d"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "e"},
+	} {
+		if err := m.Insert(mpa(e.first), mpa(e.last), e.val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []extnetip.Segment[string]
+	for seg := range m.All() {
+		got = append(got, seg)
+	}
+
+	want := []extnetip.Segment[string]{
+		{mpa("10.0.0.0"), mpa("10.0.0.99"), "a"},
+		{mpa("10.0.0.100"), mpa("10.0.0.199"), "b"},
+		{mpa("10.0.0.200"), mpa("10.0.0.255"), "a"},
+		{mpa("::"), mpa("::"), "d"},
+		{mpa("2001:db8::"), mpa("2001:db8::ffff"), "c"},
+		{mpa("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), mpa("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), "e"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("All(), got: %v, want: %v", got, want)
+	}
+
+	if val, ok := m.Lookup(mpa("10.0.0.150")); !ok || val != "b" {
+		t.Errorf("Lookup(10.0.0.150), got: %s, %v, want: b", val, ok)
+	}
+	if _, ok := m.Lookup(mpa("10.0.1.0")); ok {
+		t.Errorf("Lookup(10.0.1.0), expected not found")
+	}
+
+	var pfxs []netip.Prefix
+	for pfx, val := range m.Prefixes() {
+		if val == "b" {
+			pfxs = append(pfxs, pfx)
+		}
+	}
+	if want := pfxSlice("10.0.0.100/30", "10.0.0.104/29", "10.0.0.112/28", "10.0.0.128/26",
+		"10.0.0.192/29"); !slices.Equal(pfxs, want) {
+		t.Errorf("Prefixes(), got: %v, want: %v", pfxs, want)
+	}
+}
+
+func TestRangeMapErrorOnOverlap(t *testing.T) {
+	t.Parallel()
+	m := extnetip.NewRangeMap[int](extnetip.ErrorOnOverlap)
+
+	if err := m.Insert(mpa("10.0.0.0"), mpa("10.0.0.9"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Insert(mpa("10.0.0.10"), mpa("10.0.0.19"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Insert(mpa("10.0.0.5"), mpa("10.0.0.5"), 3); !errors.Is(err, extnetip.ErrOverlap) {
+		t.Errorf("Insert, overlap, got: %v, want: %v", err, extnetip.ErrOverlap)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Len(), got: %d, want: 2", m.Len())
+	}
+}
+
+// TestRangeMapRandom compares the range map with a brute force reference.
+func TestRangeMapRandom(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	for _, policy := range []extnetip.OverlapPolicy{extnetip.LastWins, extnetip.MostSpecificWins} {
+		m := extnetip.NewRangeMap[int](policy)
+
+		type cell struct {
+			val, span int
+			set       bool
+		}
+		var ref [256]cell
+
+		for n := range 500 {
+			lo, hi := prng.IntN(256), prng.IntN(256)
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+
+			first := netip.AddrFrom4([4]byte{10, 0, 0, byte(lo)})
+			last := netip.AddrFrom4([4]byte{10, 0, 0, byte(hi)})
+			if err := m.Insert(first, last, n); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := lo; i <= hi; i++ {
+				if policy == extnetip.LastWins || !ref[i].set || hi-lo <= ref[i].span {
+					ref[i] = cell{n, hi - lo, true}
+				}
+			}
+
+			for i := range 256 {
+				val, ok := m.Lookup(netip.AddrFrom4([4]byte{10, 0, 0, byte(i)}))
+				if ok != ref[i].set || val != ref[i].val {
+					t.Fatalf("policy %d, Lookup(10.0.0.%d), got: %d, %v, want: %d, %v",
+						policy, i, val, ok, ref[i].val, ref[i].set)
+				}
+			}
+
+			// segments must be sorted and disjoint
+			var prev netip.Addr
+			for seg := range m.All() {
+				if prev.IsValid() && !prev.Less(seg.First) {
+					t.Fatalf("policy %d, segments not disjoint at %s", policy, seg.First)
+				}
+				prev = seg.Last
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package extnetip
+
+import "net/netip"
+
+// Rel describes how two prefixes or IP ranges relate to each other,
+// see [Relation] and [RangeRelation].
+type Rel uint8
+
+const (
+	// RelInvalid is returned for invalid input or mismatched IP versions.
+	RelInvalid Rel = iota
+
+	// RelEqual means a and b cover exactly the same addresses.
+	RelEqual
+
+	// RelContains means a contains b, but they are not equal.
+	RelContains
+
+	// RelContainedBy means b contains a, but they are not equal.
+	RelContainedBy
+
+	// RelOverlaps means a and b overlap, but neither contains the other.
+	// This relation is only possible for ranges, never for prefixes.
+	RelOverlaps
+
+	// RelAdjacent means a and b are disjoint, but there
+	// is no gap between them.
+	RelAdjacent
+
+	// RelDisjoint means a and b are disjoint and not adjacent.
+	RelDisjoint
+)
+
+var relNames = [...]string{
+	RelInvalid:     "invalid",
+	RelEqual:       "equal",
+	RelContains:    "contains",
+	RelContainedBy: "contained-by",
+	RelOverlaps:    "overlaps",
+	RelAdjacent:    "adjacent",
+	RelDisjoint:    "disjoint",
+}
+
+// String returns the name of the relation.
+func (r Rel) String() string {
+	if int(r) < len(relNames) {
+		return relNames[r]
+	}
+	return relNames[RelInvalid]
+}
+
+// Relation returns the relation of prefix a to prefix b.
+//
+// The prefixes do not have to be canonical.
+//
+// It returns RelInvalid if a prefix is invalid or if the IP versions
+// do not match.
+func Relation(a, b netip.Prefix) Rel {
+	if !a.IsValid() || !b.IsValid() {
+		return RelInvalid
+	}
+
+	aFirst, aLast := Range(a)
+	bFirst, bLast := Range(b)
+
+	return RangeRelation(aFirst, aLast, bFirst, bLast)
+}
+
+// RangeRelation returns the relation of the inclusive IP range
+// [aFirst, aLast] to the inclusive IP range [bFirst, bLast].
+//
+// It returns RelInvalid if an IP is invalid, if the IP versions
+// do not match or if first > last for any range.
+func RangeRelation(aFirst, aLast, bFirst, bLast netip.Addr) Rel {
+	if !aFirst.IsValid() || !aLast.IsValid() || !bFirst.IsValid() || !bLast.IsValid() {
+		return RelInvalid
+	}
+
+	a1, a2 := unwrap(aFirst), unwrap(aLast)
+	b1, b2 := unwrap(bFirst), unwrap(bLast)
+
+	is4 := a1.is4()
+	if a2.is4() != is4 || b1.is4() != is4 || b2.is4() != is4 {
+		return RelInvalid
+	}
+
+	// Ensure ordering: first <= last
+	if a1.ip.compare(a2.ip) == 1 || b1.ip.compare(b2.ip) == 1 {
+		return RelInvalid
+	}
+
+	return rangeRelation(a1.ip, a2.ip, b1.ip, b2.ip)
+}
+
+// rangeRelation compares the ranges [a1, a2] and [b1, b2] in uint128 space.
+//
+// Precondition: same IP version, a1 <= a2 and b1 <= b2.
+func rangeRelation(a1, a2, b1, b2 uint128) Rel {
+	cmpFirst := a1.compare(b1)
+	cmpLast := a2.compare(b2)
+
+	switch {
+	case cmpFirst == 0 && cmpLast == 0:
+		return RelEqual
+	case cmpFirst <= 0 && cmpLast >= 0:
+		return RelContains
+	case cmpFirst >= 0 && cmpLast <= 0:
+		return RelContainedBy
+	}
+
+	// a is left of b, the last+1 can't overflow since last < first
+	if a2.compare(b1) == -1 {
+		if a2.addOne() == b1 {
+			return RelAdjacent
+		}
+		return RelDisjoint
+	}
+
+	// b is left of a
+	if b2.compare(a1) == -1 {
+		if b2.addOne() == a1 {
+			return RelAdjacent
+		}
+		return RelDisjoint
+	}
+
+	return RelOverlaps
+}
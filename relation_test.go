@@ -0,0 +1,80 @@
+package extnetip_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestRelation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		a    netip.Prefix
+		b    netip.Prefix
+		want extnetip.Rel
+	}{
+		{netip.Prefix{}, mpp("10.0.0.0/8"), extnetip.RelInvalid},
+		{mpp("10.0.0.0/8"), mpp("::/0"), extnetip.RelInvalid},
+		{mpp("10.0.0.0/8"), mpp("10.0.0.0/8"), extnetip.RelEqual},
+		{mpp("10.0.0.0/8"), mpp("10.1.2.3/8"), extnetip.RelEqual},
+		{mpp("10.0.0.0/8"), mpp("10.1.0.0/16"), extnetip.RelContains},
+		{mpp("10.1.0.0/16"), mpp("10.0.0.0/8"), extnetip.RelContainedBy},
+		{mpp("10.0.0.0/9"), mpp("10.128.0.0/9"), extnetip.RelAdjacent},
+		{mpp("10.128.0.0/9"), mpp("10.0.0.0/9"), extnetip.RelAdjacent},
+		{mpp("10.0.0.0/8"), mpp("12.0.0.0/8"), extnetip.RelDisjoint},
+		{mpp("::/1"), mpp("8000::/1"), extnetip.RelAdjacent},
+		{mpp("2001:db8::/32"), mpp("2001:db8::1/128"), extnetip.RelContains},
+		{mpp("fe80::/10"), mpp("2001:db8::/32"), extnetip.RelDisjoint},
+	}
+
+	for _, tt := range tests {
+		got := extnetip.Relation(tt.a, tt.b)
+		if got != tt.want {
+			t.Errorf("Relation(%s, %s), got: %s, want: %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRangeRelation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		aFirst, aLast netip.Addr
+		bFirst, bLast netip.Addr
+		want          extnetip.Rel
+	}{
+		{netip.Addr{}, mpa("10.0.0.1"), mpa("10.0.0.0"), mpa("10.0.0.1"), extnetip.RelInvalid},
+		{mpa("10.0.0.2"), mpa("10.0.0.1"), mpa("10.0.0.0"), mpa("10.0.0.1"), extnetip.RelInvalid},
+		{mpa("10.0.0.0"), mpa("10.0.0.1"), mpa("::"), mpa("::1"), extnetip.RelInvalid},
+		{mpa("10.0.0.0"), mpa("10.0.0.1"), mpa("::ffff:10.0.0.0"), mpa("::ffff:10.0.0.1"), extnetip.RelInvalid},
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.0"), mpa("10.0.0.9"), extnetip.RelEqual},
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.0"), mpa("10.0.0.5"), extnetip.RelContains},
+		{mpa("10.0.0.3"), mpa("10.0.0.9"), mpa("10.0.0.0"), mpa("10.0.0.9"), extnetip.RelContainedBy},
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.5"), mpa("10.0.0.19"), extnetip.RelOverlaps},
+		{mpa("10.0.0.5"), mpa("10.0.0.19"), mpa("10.0.0.0"), mpa("10.0.0.9"), extnetip.RelOverlaps},
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.10"), mpa("10.0.0.19"), extnetip.RelAdjacent},
+		{mpa("10.0.0.10"), mpa("10.0.0.19"), mpa("10.0.0.0"), mpa("10.0.0.9"), extnetip.RelAdjacent},
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.11"), mpa("10.0.0.19"), extnetip.RelDisjoint},
+		{mpa("0.0.0.0"), mpa("0.0.0.0"), mpa("255.255.255.255"), mpa("255.255.255.255"), extnetip.RelDisjoint},
+		{mpa("::"), mpa("::"), mpa("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), mpa("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), extnetip.RelDisjoint},
+		{mpa("::1"), mpa("::ffff"), mpa("::1:0"), mpa("::2:0"), extnetip.RelAdjacent},
+	}
+
+	for _, tt := range tests {
+		got := extnetip.RangeRelation(tt.aFirst, tt.aLast, tt.bFirst, tt.bLast)
+		if got != tt.want {
+			t.Errorf("RangeRelation(%s-%s, %s-%s), got: %s, want: %s",
+				tt.aFirst, tt.aLast, tt.bFirst, tt.bLast, got, tt.want)
+		}
+	}
+}
+
+func TestRelString(t *testing.T) {
+	t.Parallel()
+	if got := extnetip.RelContainedBy.String(); got != "contained-by" {
+		t.Errorf("String(), got: %s, want: contained-by", got)
+	}
+	if got := extnetip.Rel(255).String(); got != "invalid" {
+		t.Errorf("String(), got: %s, want: invalid", got)
+	}
+}
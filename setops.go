@@ -0,0 +1,283 @@
+package extnetip
+
+import (
+	"iter"
+	"net/netip"
+	"slices"
+)
+
+// UnionSeq returns an iterator over the minimal sorted set of prefixes
+// covering all addresses in a or b.
+//
+// The input iterators must be sorted in CIDR order, see [ComparePrefix].
+// They may contain overlapping prefixes, invalid prefixes are skipped.
+// The result is computed lazily with bounded memory.
+func UnionSeq(a, b iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix] {
+	return setOp(a, b, func(inA, inB bool) bool { return inA || inB })
+}
+
+// IntersectSeq returns an iterator over the minimal sorted set of prefixes
+// covering all addresses in a and b.
+//
+// The input iterators must be sorted in CIDR order, see [UnionSeq].
+func IntersectSeq(a, b iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix] {
+	return setOp(a, b, func(inA, inB bool) bool { return inA && inB })
+}
+
+// DiffSeq returns an iterator over the minimal sorted set of prefixes
+// covering all addresses in a but not in b.
+//
+// The input iterators must be sorted in CIDR order, see [UnionSeq].
+func DiffSeq(a, b iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix] {
+	return setOp(a, b, func(inA, inB bool) bool { return inA && !inB })
+}
+
+// XorSeq returns an iterator over the minimal sorted set of prefixes
+// covering all addresses either in a or in b, but not in both.
+//
+// The input iterators must be sorted in CIDR order, see [UnionSeq].
+func XorSeq(a, b iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix] {
+	return setOp(a, b, func(inA, inB bool) bool { return inA != inB })
+}
+
+// setOp sweeps over the merged intervals of a and b and yields the minimal
+// prefixes for all segments where keep returns true.
+//
+// A segment is a maximal range of addresses with the same membership in a and b.
+// Adjacent kept segments are coalesced and decomposed into prefixes with [All].
+func setOp(a, b iter.Seq[netip.Prefix], keep func(inA, inB bool) bool) iter.Seq[netip.Prefix] {
+	return func(yield func(netip.Prefix) bool) {
+		nextA, stopA := iter.Pull(intervals(a))
+		defer stopA()
+
+		nextB, stopB := iter.Pull(intervals(b))
+		defer stopB()
+
+		curA, okA := nextA()
+		curB, okB := nextB()
+
+		out := coalescer{yield: yield}
+
+		var pos addr
+		posOK := false
+
+		for okA || okB {
+			// jump to the next interval start if pos is not covered
+			if !posOK || !(okA && curA.contains(pos)) && !(okB && curB.contains(pos)) {
+				switch {
+				case !okB:
+					pos = curA.first
+				case !okA:
+					pos = curB.first
+				case compareAddrs(curA.first, curB.first) <= 0:
+					pos = curA.first
+				default:
+					pos = curB.first
+				}
+			}
+
+			inA := okA && curA.contains(pos)
+			inB := okB && curB.contains(pos)
+
+			// the segment ends at the nearest interval end
+			// or just before the nearest interval start
+			end := lastIP(pos.is4())
+			end = clipEnd(end, pos, curA, okA, inA)
+			end = clipEnd(end, pos, curB, okB, inB)
+
+			if keep(inA, inB) && !out.add(interval{pos, fromUint128(end, pos.is4())}) {
+				return
+			}
+
+			if inA && curA.last.ip == end {
+				curA, okA = nextA()
+			}
+			if inB && curB.last.ip == end {
+				curB, okB = nextB()
+			}
+
+			// next position, unless end was the last address of this IP version
+			posOK = end != lastIP(pos.is4())
+			pos = fromUint128(end.addOne(), pos.is4())
+		}
+
+		out.flush()
+	}
+}
+
+// clipEnd limits the segment end starting at pos by the current
+// interval iv, either by its end or just before its start.
+func clipEnd(end uint128, pos addr, iv interval, ok, in bool) uint128 {
+	switch {
+	case in:
+		return minIP(end, iv.last.ip)
+	case ok && iv.first.is4() == pos.is4():
+		return minIP(end, iv.first.ip.subOne())
+	}
+	return end
+}
+
+// interval is the inclusive address range [first, last]
+// in uint128 space, first and last have the same IP version.
+type interval struct {
+	first addr
+	last  addr
+}
+
+// prefixInterval returns the interval covered by the valid prefix p.
+func prefixInterval(p netip.Prefix) interval {
+	pa := unwrap(p.Addr())
+
+	bits := p.Bits()
+	if pa.is4() {
+		bits += 96
+	}
+	mask := mask6(bits)
+
+	first128 := pa.ip.and(mask)
+	last128 := first128.or(mask.not())
+
+	return interval{fromUint128(first128, pa.is4()), fromUint128(last128, pa.is4())}
+}
+
+// contains reports whether the interval contains the address a.
+func (iv *interval) contains(a addr) bool {
+	return iv.first.is4() == a.is4() &&
+		iv.first.ip.compare(a.ip) <= 0 &&
+		iv.last.ip.compare(a.ip) >= 0
+}
+
+// joins reports whether next overlaps or is adjacent to iv,
+// next must not start before iv.
+func (iv *interval) joins(next interval) bool {
+	if iv.first.is4() != next.first.is4() {
+		return false
+	}
+	return iv.last.ip.compare(next.first.ip) >= 0 || iv.last.ip.addOne() == next.first.ip
+}
+
+// intervals returns an iterator over the maximal disjoint intervals
+// covered by the sorted prefixes in seq, overlapping and adjacent
+// prefixes are merged, invalid prefixes are skipped.
+func intervals(seq iter.Seq[netip.Prefix]) iter.Seq[interval] {
+	return func(yield func(interval) bool) {
+		var cur interval
+		have := false
+
+		for pfx := range seq {
+			if !pfx.IsValid() {
+				continue
+			}
+
+			next := prefixInterval(pfx)
+			if have && cur.joins(next) {
+				if next.last.ip.compare(cur.last.ip) > 0 {
+					cur.last = next.last
+				}
+				continue
+			}
+
+			if have && !yield(cur) {
+				return
+			}
+			cur, have = next, true
+		}
+
+		if have {
+			yield(cur)
+		}
+	}
+}
+
+// coalescer merges adjacent sorted intervals and yields the
+// minimal prefixes for each merged interval.
+type coalescer struct {
+	cur   interval
+	have  bool
+	yield func(netip.Prefix) bool
+}
+
+// add appends the next interval, it returns false if yield returned false.
+func (c *coalescer) add(iv interval) bool {
+	if c.have && c.cur.joins(iv) {
+		c.cur.last = iv.last
+		return true
+	}
+
+	if !c.flush() {
+		return false
+	}
+
+	c.cur, c.have = iv, true
+	return true
+}
+
+// flush yields the prefixes of the pending interval, it returns false
+// if yield returned false.
+func (c *coalescer) flush() bool {
+	if !c.have {
+		return true
+	}
+	c.have = false
+	return allRec(c.cur.first, c.cur.last, c.yield)
+}
+
+// compareAddrs compares a and b, IPv4 before IPv6, then by uint128 value.
+func compareAddrs(a, b addr) int {
+	if c := compareIs4(a.is4(), b.is4()); c != 0 {
+		return c
+	}
+	return a.ip.compare(b.ip)
+}
+
+// minIP returns the smaller uint128 value.
+func minIP(a, b uint128) uint128 {
+	if a.compare(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// lastIP returns the uint128 value of the last address of the IP version.
+func lastIP(is4 bool) uint128 {
+	if is4 {
+		return lastIP4
+	}
+	return uint128{^uint64(0), ^uint64(0)}
+}
+
+// lastIP4 is the uint128 value of 255.255.255.255, it depends on the
+// conversion mode.
+var lastIP4 = unwrap(netip.AddrFrom4([4]byte{255, 255, 255, 255})).ip
+
+// DiffSets compares the address space covered by the prefix sets oldSet and
+// newSet. It returns iterators over the minimal sorted prefixes covering the
+// addresses added in newSet and the addresses removed from oldSet.
+//
+// The inputs do not have to be sorted and may contain overlapping prefixes,
+// both are collected and sorted once. Only the covered address space counts,
+// e.g. 10.0.0.0/8 and the two halves 10.0.0.0/9 and 10.128.0.0/9 are equal.
+func DiffSets(oldSet, newSet iter.Seq[netip.Prefix]) (added, removed iter.Seq[netip.Prefix]) {
+	oldPfxs := sortedPrefixes(oldSet)
+	newPfxs := sortedPrefixes(newSet)
+
+	added = DiffSeq(slices.Values(newPfxs), slices.Values(oldPfxs))
+	removed = DiffSeq(slices.Values(oldPfxs), slices.Values(newPfxs))
+	return
+}
+
+// EqualSets reports whether the prefix sets a and b cover exactly
+// the same address space, see [DiffSets].
+func EqualSets(a, b iter.Seq[netip.Prefix]) bool {
+	for range XorSeq(slices.Values(sortedPrefixes(a)), slices.Values(sortedPrefixes(b))) {
+		return false
+	}
+	return true
+}
+
+// sortedPrefixes collects the prefixes from seq and sorts them in CIDR order.
+func sortedPrefixes(seq iter.Seq[netip.Prefix]) []netip.Prefix {
+	pfxs := slices.Collect(seq)
+	SortPrefixes(pfxs)
+	return pfxs
+}
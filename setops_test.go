@@ -0,0 +1,190 @@
+package extnetip_test
+
+import (
+	"iter"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestSetOps(t *testing.T) {
+	t.Parallel()
+	a := pfxSlice("10.0.0.0/24", "10.0.1.0/24", "10.0.4.0/22", "192.168.0.0/16", "2001:db8::/32")
+	b := pfxSlice("10.0.0.128/25", "10.0.2.0/23", "10.0.6.0/24", "172.16.0.0/12", "2001:db8:1::/48", "fe80::/10")
+
+	tests := []struct {
+		name string
+		op   func(a, b iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix]
+		want []netip.Prefix
+	}{
+		{"union", extnetip.UnionSeq, pfxSlice(
+			"10.0.0.0/21", "172.16.0.0/12", "192.168.0.0/16", "2001:db8::/32", "fe80::/10",
+		)},
+		{"intersect", extnetip.IntersectSeq, pfxSlice(
+			"10.0.0.128/25", "10.0.6.0/24", "2001:db8:1::/48",
+		)},
+		{"diff", extnetip.DiffSeq, pfxSlice(
+			"10.0.0.0/25", "10.0.1.0/24", "10.0.4.0/23", "10.0.7.0/24", "192.168.0.0/16",
+			"2001:db8::/48", "2001:db8:2::/47", "2001:db8:4::/46", "2001:db8:8::/45", "2001:db8:10::/44",
+			"2001:db8:20::/43", "2001:db8:40::/42", "2001:db8:80::/41", "2001:db8:100::/40",
+			"2001:db8:200::/39", "2001:db8:400::/38", "2001:db8:800::/37", "2001:db8:1000::/36",
+			"2001:db8:2000::/35", "2001:db8:4000::/34", "2001:db8:8000::/33",
+		)},
+		{"xor", extnetip.XorSeq, pfxSlice(
+			"10.0.0.0/25", "10.0.1.0/24", "10.0.2.0/23", "10.0.4.0/23", "10.0.7.0/24",
+			"172.16.0.0/12", "192.168.0.0/16",
+			"2001:db8::/48", "2001:db8:2::/47", "2001:db8:4::/46", "2001:db8:8::/45", "2001:db8:10::/44",
+			"2001:db8:20::/43", "2001:db8:40::/42", "2001:db8:80::/41", "2001:db8:100::/40",
+			"2001:db8:200::/39", "2001:db8:400::/38", "2001:db8:800::/37", "2001:db8:1000::/36",
+			"2001:db8:2000::/35", "2001:db8:4000::/34", "2001:db8:8000::/33", "fe80::/10",
+		)},
+	}
+
+	for _, tt := range tests {
+		got := slices.Collect(tt.op(slices.Values(a), slices.Values(b)))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s, got: %v, want: %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetOpsEdges(t *testing.T) {
+	t.Parallel()
+	all4 := pfxSlice("0.0.0.0/0")
+	all6 := pfxSlice("::/0")
+	both := pfxSlice("0.0.0.0/0", "::/0")
+
+	tests := []struct {
+		name string
+		got  iter.Seq[netip.Prefix]
+		want []netip.Prefix
+	}{
+		{"empty", extnetip.UnionSeq(slices.Values([]netip.Prefix(nil)), slices.Values([]netip.Prefix(nil))), nil},
+		{"invalid", extnetip.UnionSeq(slices.Values([]netip.Prefix{{}}), slices.Values(all4)), all4},
+		{"union families", extnetip.UnionSeq(slices.Values(all4), slices.Values(all6)), both},
+		{"intersect families", extnetip.IntersectSeq(slices.Values(all4), slices.Values(all6)), nil},
+		{"diff to end", extnetip.DiffSeq(slices.Values(both), slices.Values(pfxSlice("0.0.0.0/1", "::/1"))),
+			pfxSlice("128.0.0.0/1", "8000::/1")},
+		{"diff from start", extnetip.DiffSeq(slices.Values(both), slices.Values(pfxSlice("128.0.0.0/1", "8000::/1"))),
+			pfxSlice("0.0.0.0/1", "::/1")},
+		{"xor self", extnetip.XorSeq(slices.Values(both), slices.Values(both)), nil},
+		{"adjacent", extnetip.UnionSeq(slices.Values(pfxSlice("10.0.0.0/25")), slices.Values(pfxSlice("10.0.0.128/25"))),
+			pfxSlice("10.0.0.0/24")},
+	}
+
+	for _, tt := range tests {
+		got := slices.Collect(tt.got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s, got: %v, want: %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestSetOpsRandom compares the set operations with a brute force
+// reference implementation on a small address space.
+func TestSetOpsRandom(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	randSet := func() []netip.Prefix {
+		var pfxs []netip.Prefix
+		for range prng.IntN(8) {
+			a := netip.AddrFrom4([4]byte{10, 0, 0, byte(prng.IntN(256))})
+			pfxs = append(pfxs, netip.PrefixFrom(a, 24+prng.IntN(9)).Masked())
+		}
+		slices.SortFunc(pfxs, extnetip.ComparePrefix)
+		return pfxs
+	}
+
+	members := func(pfxs []netip.Prefix) (set [256]bool) {
+		for _, p := range pfxs {
+			first, last := extnetip.Range(p)
+			for i := first.As4()[3]; ; i++ {
+				set[i] = true
+				if i == last.As4()[3] {
+					break
+				}
+			}
+		}
+		return
+	}
+
+	ops := []struct {
+		name string
+		op   func(a, b iter.Seq[netip.Prefix]) iter.Seq[netip.Prefix]
+		keep func(inA, inB bool) bool
+	}{
+		{"union", extnetip.UnionSeq, func(inA, inB bool) bool { return inA || inB }},
+		{"intersect", extnetip.IntersectSeq, func(inA, inB bool) bool { return inA && inB }},
+		{"diff", extnetip.DiffSeq, func(inA, inB bool) bool { return inA && !inB }},
+		{"xor", extnetip.XorSeq, func(inA, inB bool) bool { return inA != inB }},
+	}
+
+	for range 1_000 {
+		a, b := randSet(), randSet()
+		setA, setB := members(a), members(b)
+
+		for _, tt := range ops {
+			// reference: decompose the maximal ranges of kept addresses
+			var want []netip.Prefix
+			for i := 0; i < 256; i++ {
+				if !tt.keep(setA[i], setB[i]) {
+					continue
+				}
+				j := i
+				for j < 255 && tt.keep(setA[j+1], setB[j+1]) {
+					j++
+				}
+				first := netip.AddrFrom4([4]byte{10, 0, 0, byte(i)})
+				last := netip.AddrFrom4([4]byte{10, 0, 0, byte(j)})
+				want = slices.AppendSeq(want, extnetip.All(first, last))
+				i = j
+			}
+
+			got := slices.Collect(tt.op(slices.Values(a), slices.Values(b)))
+			if !slices.Equal(got, want) {
+				t.Fatalf("%s(%v, %v), got: %v, want: %v", tt.name, a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestDiffSets(t *testing.T) {
+	t.Parallel()
+	oldSet := pfxSlice("10.0.0.0/8", "192.168.1.0/24", "2001:db8::/32")
+	newSet := pfxSlice("2001:db8::/32", "10.128.0.0/9", "10.0.0.0/9", "192.168.1.0/25", "172.16.0.0/12")
+
+	added, removed := extnetip.DiffSets(slices.Values(oldSet), slices.Values(newSet))
+
+	if got, want := slices.Collect(added), pfxSlice("172.16.0.0/12"); !slices.Equal(got, want) {
+		t.Errorf("DiffSets, added: %v, want: %v", got, want)
+	}
+	if got, want := slices.Collect(removed), pfxSlice("192.168.1.128/25"); !slices.Equal(got, want) {
+		t.Errorf("DiffSets, removed: %v, want: %v", got, want)
+	}
+}
+
+func TestEqualSets(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		a, b []netip.Prefix
+		want bool
+	}{
+		{nil, nil, true},
+		{pfxSlice("10.0.0.0/8"), nil, false},
+		{pfxSlice("10.0.0.0/8"), pfxSlice("10.128.0.0/9", "10.0.0.0/9"), true},
+		{pfxSlice("10.0.0.0/8"), pfxSlice("10.0.0.0/9", "10.1.0.0/16", "10.128.0.0/9"), true},
+		{pfxSlice("10.0.0.0/8"), pfxSlice("10.0.0.0/9"), false},
+		{pfxSlice("10.0.0.0/8", "::/0"), pfxSlice("::/1", "10.0.0.0/8", "8000::/1"), true},
+		{pfxSlice("0.0.0.0/0"), pfxSlice("::/0"), false},
+	}
+
+	for _, tt := range tests {
+		if got := extnetip.EqualSets(slices.Values(tt.a), slices.Values(tt.b)); got != tt.want {
+			t.Errorf("EqualSets(%v, %v), got: %v, want: %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
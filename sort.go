@@ -0,0 +1,296 @@
+package extnetip
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+)
+
+// ComparePrefix returns an integer comparing two prefixes in CIDR order,
+// suitable for slices.SortFunc.
+//
+// The result will be 0 if a == b, -1 if a < b, and +1 if a > b.
+//
+// The order is the same as for netip.Prefix.Compare in Go 1.26: prefixes
+// sort first by validity (invalid before valid), then address family (IPv4 before IPv6),
+// then masked prefix address, then prefix length, then unmasked address.
+//
+// The comparisons are done in uint128 space.
+func ComparePrefix(a, b netip.Prefix) int {
+	if c := compareValid(a.IsValid(), b.IsValid()); c != 0 || !a.IsValid() {
+		return c
+	}
+
+	ua := unwrap(a.Addr())
+	ub := unwrap(b.Addr())
+
+	// IPv4 before IPv6
+	if c := compareIs4(ua.is4(), ub.is4()); c != 0 {
+		return c
+	}
+
+	bitsA, bitsB := a.Bits(), b.Bits()
+
+	offset := 0
+	if ua.is4() {
+		offset = 96 // IPv4 addresses are embedded in IPv6 space with a 96-bit prefix
+	}
+
+	// masked prefix address
+	if c := ua.ip.and(mask6(bitsA + offset)).compare(ub.ip.and(mask6(bitsB + offset))); c != 0 {
+		return c
+	}
+
+	// prefix length
+	if c := cmp.Compare(bitsA, bitsB); c != 0 {
+		return c
+	}
+
+	// unmasked address
+	return ua.ip.compare(ub.ip)
+}
+
+// CompareRange returns an integer comparing the inclusive IP range
+// [aFirst, aLast] with the inclusive IP range [bFirst, bLast].
+//
+// The result will be 0 if the ranges are equal, -1 if a < b, and +1 if a > b.
+//
+// Ranges sort first by the first address and then by the last address
+// in reverse order, this way a range sorts before all the ranges it
+// contains, the same as prefixes in CIDR order.
+//
+// The addresses are compared with the same order as netip.Addr.Compare,
+// the comparisons are done in uint128 space.
+func CompareRange(aFirst, aLast, bFirst, bLast netip.Addr) int {
+	if c := compareAddr(aFirst, bFirst); c != 0 {
+		return c
+	}
+	return compareAddr(bLast, aLast)
+}
+
+// compareAddr returns the same result as a.Compare(b),
+// but does the comparison in uint128 space.
+func compareAddr(a, b netip.Addr) int {
+	if c := compareValid(a.IsValid(), b.IsValid()); c != 0 || !a.IsValid() {
+		return c
+	}
+
+	ua := unwrap(a)
+	ub := unwrap(b)
+
+	if c := compareIs4(ua.is4(), ub.is4()); c != 0 {
+		return c
+	}
+
+	if c := ua.ip.compare(ub.ip); c != 0 {
+		return c
+	}
+
+	// equal IPs, compare the zones, rare
+	return a.Compare(b)
+}
+
+// compareValid returns an integer comparing the validity, invalid before valid.
+func compareValid(a, b bool) int {
+	if a == b {
+		return 0
+	}
+	if !a {
+		return -1
+	}
+	return 1
+}
+
+// compareIs4 returns an integer comparing the address family, IPv4 before IPv6.
+func compareIs4(a, b bool) int {
+	if a == b {
+		return 0
+	}
+	if a {
+		return -1
+	}
+	return 1
+}
+
+// radixThreshold is the slice length below which the radix sort
+// falls back to a comparison sort.
+const radixThreshold = 64
+
+// SortAddrs sorts a slice of addresses in ascending order,
+// the result is the same as slices.SortFunc(addrs, netip.Addr.Compare).
+//
+// It uses an MSD radix sort on the uint128 representation,
+// which is much faster than a comparison sort for large slices,
+// at the cost of O(n) additional memory.
+func SortAddrs(addrs []netip.Addr) {
+	if len(addrs) < radixThreshold {
+		slices.SortFunc(addrs, compareAddr)
+		return
+	}
+
+	items := make([]sortItem[netip.Addr], len(addrs))
+	for i, a := range addrs {
+		items[i].val = a
+		if a.IsValid() {
+			ua := unwrap(a)
+			items[i].key = ua.ip
+			items[i].rank = familyRank(ua.is4())
+		}
+	}
+
+	radixSort(items, compareAddr)
+
+	for i := range items {
+		addrs[i] = items[i].val
+	}
+}
+
+// SortPrefixes sorts a slice of prefixes in CIDR order,
+// the result is the same as slices.SortFunc(pfxs, ComparePrefix).
+//
+// It uses an MSD radix sort on the uint128 representation of the
+// masked prefix address, which is much faster than a comparison sort
+// for large slices, at the cost of O(n) additional memory.
+func SortPrefixes(pfxs []netip.Prefix) {
+	if len(pfxs) < radixThreshold {
+		slices.SortFunc(pfxs, ComparePrefix)
+		return
+	}
+
+	items := make([]sortItem[netip.Prefix], len(pfxs))
+	for i, p := range pfxs {
+		items[i].val = p
+		if p.IsValid() {
+			ua := unwrap(p.Addr())
+
+			bits := p.Bits()
+			if ua.is4() {
+				bits += 96
+			}
+
+			items[i].key = ua.ip.and(mask6(bits))
+			items[i].rank = familyRank(ua.is4())
+		}
+	}
+
+	radixSort(items, ComparePrefix)
+
+	for i := range items {
+		pfxs[i] = items[i].val
+	}
+}
+
+// sortItem is the element type for the radix sort, the
+// value is sorted by rank, key and finally by a compare func.
+type sortItem[T any] struct {
+	key  uint128
+	rank uint8
+	val  T
+}
+
+// familyRank returns the rank for the address family,
+// rank 0 is reserved for invalid values.
+func familyRank(is4 bool) uint8 {
+	if is4 {
+		return 1
+	}
+	return 2
+}
+
+// digit returns the d-th byte of u, counted from the least significant byte.
+func (u uint128) digit(d int) uint8 {
+	if d < 8 {
+		return uint8(u.lo >> (8 * d))
+	}
+	return uint8(u.hi >> (8 * (d - 8)))
+}
+
+// radixSort sorts the items by rank, then by key and for equal
+// keys by the compare func for the values.
+func radixSort[T any](items []sortItem[T], cmp func(a, b T) int) {
+	buf := make([]sortItem[T], len(items))
+
+	// partition by rank with a counting sort
+	var offsets [3 + 1]int
+	for i := range items {
+		offsets[items[i].rank+1]++
+	}
+	for i := 1; i < len(offsets); i++ {
+		offsets[i] += offsets[i-1]
+	}
+	bounds := offsets
+
+	for i := range items {
+		r := items[i].rank
+		buf[offsets[r]] = items[i]
+		offsets[r]++
+	}
+	copy(items, buf)
+
+	// invalid values are all equal
+	for r := 1; r < 3; r++ {
+		lo, hi := bounds[r], bounds[r+1]
+		msdSort(items[lo:hi], buf[lo:hi], 15, cmp)
+	}
+}
+
+// msdSort sorts items by key with a most significant digit first
+// radix sort, starting with digit d, buf must have the same length
+// as items.
+//
+// Small buckets and buckets with equal keys are sorted with a
+// comparison sort, using the compare func for equal keys.
+func msdSort[T any](items, buf []sortItem[T], d int, cmp func(a, b T) int) {
+	for {
+		if len(items) < radixThreshold {
+			slices.SortFunc(items, func(a, b sortItem[T]) int {
+				if c := a.key.compare(b.key); c != 0 {
+					return c
+				}
+				return cmp(a.val, b.val)
+			})
+			return
+		}
+
+		// all keys are equal
+		if d < 0 {
+			slices.SortFunc(items, func(a, b sortItem[T]) int { return cmp(a.val, b.val) })
+			return
+		}
+
+		var counts [256]int
+		for i := range items {
+			counts[items[i].key.digit(d)]++
+		}
+
+		// all keys share this digit, e.g. the upper bytes of IPv4 addresses
+		if counts[items[0].key.digit(d)] == len(items) {
+			d--
+			continue
+		}
+
+		var offsets [256]int
+		sum := 0
+		for i, c := range counts {
+			offsets[i] = sum
+			sum += c
+		}
+
+		for i := range items {
+			b := items[i].key.digit(d)
+			buf[offsets[b]] = items[i]
+			offsets[b]++
+		}
+		copy(items, buf)
+
+		// recurse into the buckets
+		lo := 0
+		for _, c := range counts {
+			if c > 1 {
+				msdSort(items[lo:lo+c], buf[lo:lo+c], d-1, cmp)
+			}
+			lo += c
+		}
+		return
+	}
+}
@@ -0,0 +1,176 @@
+package extnetip_test
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func randAddr(prng *rand.Rand) netip.Addr {
+	switch prng.IntN(8) {
+	case 0:
+		return netip.Addr{}
+	case 1, 2, 3:
+		var a4 [4]byte
+		for i := range a4 {
+			a4[i] = byte(prng.IntN(4)) // force duplicates and shared digits
+		}
+		return netip.AddrFrom4(a4)
+	default:
+		var a16 [16]byte
+		for i := range a16 {
+			a16[i] = byte(prng.IntN(4))
+		}
+		return netip.AddrFrom16(a16)
+	}
+}
+
+func randPrefix(prng *rand.Rand) netip.Prefix {
+	a := randAddr(prng)
+	if !a.IsValid() {
+		return netip.Prefix{}
+	}
+	p := netip.PrefixFrom(a, prng.IntN(a.BitLen()+1))
+	if prng.IntN(2) == 0 {
+		return p.Masked()
+	}
+	return p
+}
+
+// comparePrefixRef is the reference implementation for ComparePrefix
+// with the order of netip.Prefix.Compare, available only since Go 1.26.
+func comparePrefixRef(a, b netip.Prefix) int {
+	if c := cmp.Compare(boolInt(a.IsValid()), boolInt(b.IsValid())); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Addr().BitLen(), b.Addr().BitLen()); c != 0 {
+		return c
+	}
+	if c := a.Masked().Addr().Compare(b.Masked().Addr()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Bits(), b.Bits()); c != 0 {
+		return c
+	}
+	return a.Addr().Compare(b.Addr())
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestComparePrefix(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	for range 10_000 {
+		a, b := randPrefix(prng), randPrefix(prng)
+		got := extnetip.ComparePrefix(a, b)
+		want := comparePrefixRef(a, b)
+		if got != want {
+			t.Fatalf("ComparePrefix(%s, %s), got: %d, want: %d", a, b, got, want)
+		}
+	}
+}
+
+func TestCompareRange(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		aFirst, aLast netip.Addr
+		bFirst, bLast netip.Addr
+		want          int
+	}{
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.0"), mpa("10.0.0.9"), 0},
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.1"), mpa("10.0.0.5"), -1},
+		{mpa("10.0.0.1"), mpa("10.0.0.5"), mpa("10.0.0.0"), mpa("10.0.0.9"), 1},
+		{mpa("10.0.0.0"), mpa("10.0.0.9"), mpa("10.0.0.0"), mpa("10.0.0.5"), -1}, // superset first
+		{mpa("10.0.0.0"), mpa("10.0.0.5"), mpa("10.0.0.0"), mpa("10.0.0.9"), 1},
+		{mpa("::"), mpa("::9"), mpa("10.0.0.0"), mpa("10.0.0.9"), 1}, // IPv4 before IPv6
+		{netip.Addr{}, netip.Addr{}, mpa("10.0.0.0"), mpa("10.0.0.9"), -1},
+	}
+
+	for _, tt := range tests {
+		got := extnetip.CompareRange(tt.aFirst, tt.aLast, tt.bFirst, tt.bLast)
+		if got != tt.want {
+			t.Errorf("CompareRange(%s-%s, %s-%s), got: %d, want: %d",
+				tt.aFirst, tt.aLast, tt.bFirst, tt.bLast, got, tt.want)
+		}
+	}
+}
+
+func TestSortAddrs(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	for _, n := range []int{0, 1, 10, 1_000, 10_000} {
+		addrs := make([]netip.Addr, n)
+		for i := range addrs {
+			addrs[i] = randAddr(prng)
+		}
+
+		want := slices.Clone(addrs)
+		slices.SortFunc(want, netip.Addr.Compare)
+
+		extnetip.SortAddrs(addrs)
+		if !slices.Equal(addrs, want) {
+			t.Fatalf("SortAddrs, n=%d, result not sorted", n)
+		}
+	}
+
+	// with zones
+	addrs := make([]netip.Addr, 1_000)
+	for i := range addrs {
+		addrs[i] = randAddr(prng)
+	}
+	addrs[0] = mpa("fe80::1%eth0")
+	addrs[1] = mpa("fe80::1")
+
+	want := slices.Clone(addrs)
+	slices.SortFunc(want, netip.Addr.Compare)
+
+	extnetip.SortAddrs(addrs)
+	if !slices.Equal(addrs, want) {
+		t.Fatalf("SortAddrs with zones, result not sorted")
+	}
+}
+
+func TestSortPrefixes(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	for _, n := range []int{0, 1, 10, 1_000, 10_000} {
+		pfxs := make([]netip.Prefix, n)
+		for i := range pfxs {
+			pfxs[i] = randPrefix(prng)
+		}
+
+		want := slices.Clone(pfxs)
+		slices.SortFunc(want, comparePrefixRef)
+
+		extnetip.SortPrefixes(pfxs)
+		if !slices.Equal(pfxs, want) {
+			t.Fatalf("SortPrefixes, n=%d, result not sorted", n)
+		}
+
+		// all canonical
+		for i := range pfxs {
+			pfxs[i] = pfxs[i].Masked()
+		}
+		prng.Shuffle(len(pfxs), func(i, j int) { pfxs[i], pfxs[j] = pfxs[j], pfxs[i] })
+
+		want = slices.Clone(pfxs)
+		slices.SortFunc(want, comparePrefixRef)
+
+		extnetip.SortPrefixes(pfxs)
+		if !slices.Equal(pfxs, want) {
+			t.Fatalf("SortPrefixes canonical, n=%d, result not sorted", n)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package extnetip
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// Table is a longest-prefix-match routing table, mapping prefixes to values.
+//
+// It is implemented as a path-compressed binary trie on the uint128
+// representation, with separate roots for IPv4 and IPv6.
+//
+// The zero value is an empty table ready to use.
+// A Table is not safe for concurrent use with writers.
+type Table[V any] struct {
+	root4 *tableNode[V]
+	root6 *tableNode[V]
+	size  int
+}
+
+// tableNode is a node in the path-compressed trie.
+//
+// The key is the masked prefix address in uint128 space and bits is the
+// prefix length in uint128 space, for IPv4 the offset of 96 bits is included.
+// Nodes without a value are branching nodes with two children.
+type tableNode[V any] struct {
+	key   uint128
+	bits  int
+	child [2]*tableNode[V]
+	value V
+	isSet bool
+}
+
+// bit returns the n-th bit of u, counted from the most significant bit.
+func (u uint128) bit(n int) uint {
+	if n < 64 {
+		return uint(u.hi>>(63-n)) & 1
+	}
+	return uint(u.lo>>(127-n)) & 1
+}
+
+// tableKey returns the key, prefix length in uint128 space and
+// the IP version for the valid prefix p.
+func tableKey(p netip.Prefix) (key uint128, bits int, is4 bool) {
+	pa := unwrap(p.Addr())
+	bits = p.Bits()
+	if pa.is4() {
+		bits += 96
+	}
+	return pa.ip.and(mask6(bits)), bits, pa.is4()
+}
+
+// covers reports whether the node prefix covers the key.
+func (n *tableNode[V]) covers(key uint128) bool {
+	return key.and(mask6(n.bits)) == n.key
+}
+
+// prefix returns the node key as netip.Prefix.
+func (n *tableNode[V]) prefix(is4 bool) netip.Prefix {
+	bits := n.bits
+	if is4 {
+		bits -= 96
+	}
+	return netip.PrefixFrom(wrap(fromUint128(n.key, is4)), bits)
+}
+
+// rootp returns a pointer to the root node for the IP version.
+func (t *Table[V]) rootp(is4 bool) **tableNode[V] {
+	if is4 {
+		return &t.root4
+	}
+	return &t.root6
+}
+
+// Len returns the number of prefixes in the table.
+func (t *Table[V]) Len() int {
+	return t.size
+}
+
+// Insert adds the prefix pfx with value val to the table,
+// an existing value for pfx is replaced.
+//
+// The prefix is stored in canonical form, invalid prefixes are ignored.
+func (t *Table[V]) Insert(pfx netip.Prefix, val V) {
+	if !pfx.IsValid() {
+		return
+	}
+	key, bits, is4 := tableKey(pfx)
+
+	leaf := &tableNode[V]{key: key, bits: bits, value: val, isSet: true}
+
+	np := t.rootp(is4)
+	for {
+		n := *np
+		if n == nil {
+			*np = leaf
+			t.size++
+			return
+		}
+
+		common := min(key.commonPrefixLen(n.key), bits, n.bits)
+
+		switch {
+		case common == n.bits && common == bits:
+			// same prefix, set or replace the value
+			if !n.isSet {
+				t.size++
+			}
+			n.value, n.isSet = val, true
+			return
+
+		case common == n.bits:
+			// n covers pfx, descend
+			np = &n.child[key.bit(n.bits)]
+			continue
+
+		case common == bits:
+			// pfx covers n, insert leaf above n
+			leaf.child[n.key.bit(bits)] = n
+
+		default:
+			// pfx and n diverge, insert a branching node
+			branch := &tableNode[V]{key: key.and(mask6(common)), bits: common}
+			branch.child[key.bit(common)] = leaf
+			branch.child[n.key.bit(common)] = n
+			leaf = branch
+		}
+
+		*np = leaf
+		t.size++
+		return
+	}
+}
+
+// Delete removes the prefix pfx from the table and returns its value,
+// ok is false if pfx was not in the table.
+func (t *Table[V]) Delete(pfx netip.Prefix) (val V, ok bool) {
+	if !pfx.IsValid() {
+		return
+	}
+	key, bits, is4 := tableKey(pfx)
+
+	// find the node and the pointer to its parent's slot
+	var parentp **tableNode[V]
+	np := t.rootp(is4)
+	for {
+		n := *np
+		if n == nil || n.bits > bits || !n.covers(key) {
+			return
+		}
+		if n.bits == bits {
+			break
+		}
+		parentp, np = np, &n.child[key.bit(n.bits)]
+	}
+
+	n := *np
+	if !n.isSet {
+		return
+	}
+	val, ok = n.value, true
+	t.size--
+
+	switch {
+	case n.child[0] != nil && n.child[1] != nil:
+		// still needed as branching node
+		var zero V
+		n.value, n.isSet = zero, false
+		return
+
+	case n.child[0] != nil:
+		*np = n.child[0]
+		return
+
+	case n.child[1] != nil:
+		*np = n.child[1]
+		return
+	}
+
+	// leaf, remove it and compact a branching parent
+	*np = nil
+	if parentp != nil {
+		if parent := *parentp; !parent.isSet {
+			*parentp = parent.child[0]
+			if *parentp == nil {
+				*parentp = parent.child[1]
+			}
+		}
+	}
+
+	return
+}
+
+// Get returns the value for the exact prefix pfx.
+func (t *Table[V]) Get(pfx netip.Prefix) (val V, ok bool) {
+	if !pfx.IsValid() {
+		return
+	}
+	key, bits, is4 := tableKey(pfx)
+
+	for n := *t.rootp(is4); n != nil && n.bits <= bits && n.covers(key); n = n.child[key.bit(n.bits)] {
+		if n.bits == bits {
+			return n.value, n.isSet
+		}
+	}
+	return
+}
+
+// Lookup returns the value of the longest prefix covering ip.
+func (t *Table[V]) Lookup(ip netip.Addr) (val V, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
+	a := unwrap(ip)
+
+	return t.lookup(a.ip, 128, a.is4())
+}
+
+// LookupPrefix returns the value of the longest prefix covering pfx,
+// including pfx itself.
+func (t *Table[V]) LookupPrefix(pfx netip.Prefix) (val V, ok bool) {
+	if !pfx.IsValid() {
+		return
+	}
+	key, bits, is4 := tableKey(pfx)
+
+	return t.lookup(key, bits, is4)
+}
+
+// lookup returns the value of the longest prefix with at most bits,
+// covering key.
+func (t *Table[V]) lookup(key uint128, bits int, is4 bool) (val V, ok bool) {
+	for n := *t.rootp(is4); n != nil && n.bits <= bits && n.covers(key); {
+		if n.isSet {
+			val, ok = n.value, true
+		}
+		if n.bits == 128 {
+			break
+		}
+		n = n.child[key.bit(n.bits)]
+	}
+	return
+}
+
+// Supernets returns an iterator over all prefixes in the table covering pfx,
+// including pfx itself, with their values.
+//
+// The prefixes are yielded in reverse CIDR order, longest prefix first.
+func (t *Table[V]) Supernets(pfx netip.Prefix) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		if !pfx.IsValid() {
+			return
+		}
+		key, bits, is4 := tableKey(pfx)
+
+		// collect the path, at most one node per prefix length
+		var path []*tableNode[V]
+		for n := *t.rootp(is4); n != nil && n.bits <= bits && n.covers(key); {
+			if n.isSet {
+				path = append(path, n)
+			}
+			if n.bits == 128 {
+				break
+			}
+			n = n.child[key.bit(n.bits)]
+		}
+
+		for i := len(path) - 1; i >= 0; i-- {
+			if !yield(path[i].prefix(is4), path[i].value) {
+				return
+			}
+		}
+	}
+}
+
+// Subnets returns an iterator over all prefixes in the table covered by pfx,
+// including pfx itself, with their values.
+//
+// The prefixes are yielded in CIDR order.
+func (t *Table[V]) Subnets(pfx netip.Prefix) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		if !pfx.IsValid() {
+			return
+		}
+		key, bits, is4 := tableKey(pfx)
+
+		// find the topmost node covered by pfx
+		n := *t.rootp(is4)
+		for n != nil && n.bits < bits {
+			if !n.covers(key) {
+				return
+			}
+			n = n.child[key.bit(n.bits)]
+		}
+
+		if n == nil || n.key.and(mask6(bits)) != key {
+			return
+		}
+
+		n.allRec(is4, yield)
+	}
+}
+
+// All2 returns an iterator over all prefixes in the table with their values.
+//
+// The prefixes are yielded in CIDR order, IPv4 before IPv6.
+func (t *Table[V]) All2() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		_ = t.root4.allRec(true, yield) && t.root6.allRec(false, yield)
+	}
+}
+
+// allRec yields the prefixes of the subtrie in pre-order, which is CIDR order.
+func (n *tableNode[V]) allRec(is4 bool, yield func(netip.Prefix, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.isSet && !yield(n.prefix(is4), n.value) {
+		return false
+	}
+	return n.child[0].allRec(is4, yield) && n.child[1].allRec(is4, yield)
+}
@@ -0,0 +1,190 @@
+package extnetip_test
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestTable(t *testing.T) {
+	t.Parallel()
+	var tbl extnetip.Table[string]
+
+	for _, s := range []string{
+		"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32", "192.168.0.0/16",
+		"::/0", "2001:db8::/32", "2001:db8:1::/48", "2001:db8::1/128",
+	} {
+		tbl.Insert(mpp(s), s)
+	}
+	tbl.Insert(netip.Prefix{}, "invalid")
+	tbl.Insert(mpp("10.1.2.3/16"), "10.1.0.0/16") // non-canonical, replace
+
+	if got := tbl.Len(); got != 10 {
+		t.Errorf("Len(), got: %d, want: 10", got)
+	}
+
+	lookups := []struct {
+		ip   netip.Addr
+		want string
+	}{
+		{mpa("10.1.2.3"), "10.1.2.3/32"},
+		{mpa("10.1.2.4"), "10.1.2.0/24"},
+		{mpa("10.1.3.4"), "10.1.0.0/16"},
+		{mpa("10.2.3.4"), "10.0.0.0/8"},
+		{mpa("11.0.0.1"), "0.0.0.0/0"},
+		{mpa("192.168.255.255"), "192.168.0.0/16"},
+		{mpa("2001:db8::1"), "2001:db8::1/128"},
+		{mpa("2001:db8:1::1"), "2001:db8:1::/48"},
+		{mpa("2001:db8:2::1"), "2001:db8::/32"},
+		{mpa("fe80::1"), "::/0"},
+	}
+
+	for _, tt := range lookups {
+		got, ok := tbl.Lookup(tt.ip)
+		if !ok || got != tt.want {
+			t.Errorf("Lookup(%s), got: %s, %v, want: %s", tt.ip, got, ok, tt.want)
+		}
+	}
+
+	if got, ok := tbl.LookupPrefix(mpp("10.1.2.0/23")); !ok || got != "10.1.0.0/16" {
+		t.Errorf("LookupPrefix(10.1.2.0/23), got: %s, want: 10.1.0.0/16", got)
+	}
+
+	if got, ok := tbl.Get(mpp("10.1.0.0/16")); !ok || got != "10.1.0.0/16" {
+		t.Errorf("Get(10.1.0.0/16), got: %s, want: 10.1.0.0/16", got)
+	}
+
+	if _, ok := tbl.Get(mpp("10.1.0.0/17")); ok {
+		t.Errorf("Get(10.1.0.0/17), expected not found")
+	}
+
+	var supernets []string
+	for pfx := range tbl.Supernets(mpp("10.1.2.0/24")) {
+		supernets = append(supernets, pfx.String())
+	}
+	if want := []string{"10.1.2.0/24", "10.1.0.0/16", "10.0.0.0/8", "0.0.0.0/0"}; !slices.Equal(supernets, want) {
+		t.Errorf("Supernets(10.1.2.0/24), got: %v, want: %v", supernets, want)
+	}
+
+	var subnets []string
+	for pfx := range tbl.Subnets(mpp("10.0.0.0/12")) {
+		subnets = append(subnets, pfx.String())
+	}
+	if want := []string{"10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32"}; !slices.Equal(subnets, want) {
+		t.Errorf("Subnets(10.0.0.0/12), got: %v, want: %v", subnets, want)
+	}
+
+	var all []netip.Prefix
+	for pfx, val := range tbl.All2() {
+		if pfx.String() != val {
+			t.Errorf("All2(), prefix %s has value %s", pfx, val)
+		}
+		all = append(all, pfx)
+	}
+	if !slices.IsSortedFunc(all, extnetip.ComparePrefix) || len(all) != 10 {
+		t.Errorf("All2(), not sorted or incomplete: %v", all)
+	}
+
+	if val, ok := tbl.Delete(mpp("10.1.2.0/24")); !ok || val != "10.1.2.0/24" {
+		t.Errorf("Delete(10.1.2.0/24), got: %s, %v", val, ok)
+	}
+	if _, ok := tbl.Delete(mpp("10.1.2.0/24")); ok {
+		t.Errorf("Delete(10.1.2.0/24) twice, expected not found")
+	}
+	if got, _ := tbl.Lookup(mpa("10.1.2.4")); got != "10.1.0.0/16" {
+		t.Errorf("Lookup(10.1.2.4) after delete, got: %s, want: 10.1.0.0/16", got)
+	}
+}
+
+// TestTableRandom compares the table with a brute force reference.
+func TestTableRandom(t *testing.T) {
+	t.Parallel()
+	prng := rand.New(rand.NewPCG(42, 42))
+
+	randPfx := func() netip.Prefix {
+		if prng.IntN(2) == 0 {
+			a := netip.AddrFrom4([4]byte{10, byte(prng.IntN(4)), byte(prng.IntN(4)), byte(prng.IntN(256))})
+			return netip.PrefixFrom(a, 8+prng.IntN(25)).Masked()
+		}
+		a := netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(prng.IntN(4)), 15: byte(prng.IntN(256))})
+		return netip.PrefixFrom(a, 16+prng.IntN(113)).Masked()
+	}
+
+	var tbl extnetip.Table[int]
+	ref := map[netip.Prefix]int{}
+
+	for i := range 5_000 {
+		pfx := randPfx()
+		if prng.IntN(3) == 0 {
+			_, ok := tbl.Delete(pfx)
+			_, refOK := ref[pfx]
+			if ok != refOK {
+				t.Fatalf("Delete(%s), got: %v, want: %v", pfx, ok, refOK)
+			}
+			delete(ref, pfx)
+			continue
+		}
+		tbl.Insert(pfx, i)
+		ref[pfx] = i
+	}
+
+	if tbl.Len() != len(ref) {
+		t.Fatalf("Len(), got: %d, want: %d", tbl.Len(), len(ref))
+	}
+
+	for range 1_000 {
+		pfx := randPfx()
+
+		wantVal, wantOK, wantBits := 0, false, -1
+		for p, v := range ref {
+			if p.Bits() <= pfx.Bits() && p.Contains(pfx.Addr()) && p.Bits() > wantBits {
+				wantVal, wantOK, wantBits = v, true, p.Bits()
+			}
+		}
+
+		gotVal, gotOK := tbl.LookupPrefix(pfx)
+		if gotVal != wantVal || gotOK != wantOK {
+			t.Fatalf("LookupPrefix(%s), got: %d, %v, want: %d, %v", pfx, gotVal, gotOK, wantVal, wantOK)
+		}
+
+		if pfx.Bits() == pfx.Addr().BitLen() {
+			gotVal, gotOK = tbl.Lookup(pfx.Addr())
+			if gotVal != wantVal || gotOK != wantOK {
+				t.Fatalf("Lookup(%s), got: %d, %v, want: %d, %v", pfx.Addr(), gotVal, gotOK, wantVal, wantOK)
+			}
+		}
+
+		var wantSubs []netip.Prefix
+		for p := range ref {
+			if p.Bits() >= pfx.Bits() && pfx.Contains(p.Addr()) {
+				wantSubs = append(wantSubs, p)
+			}
+		}
+		slices.SortFunc(wantSubs, extnetip.ComparePrefix)
+
+		var gotSubs []netip.Prefix
+		for p := range tbl.Subnets(pfx) {
+			gotSubs = append(gotSubs, p)
+		}
+		if !slices.Equal(gotSubs, wantSubs) {
+			t.Fatalf("Subnets(%s), got: %v, want: %v", pfx, gotSubs, wantSubs)
+		}
+	}
+
+	var wantAll []netip.Prefix
+	for p := range ref {
+		wantAll = append(wantAll, p)
+	}
+	slices.SortFunc(wantAll, extnetip.ComparePrefix)
+
+	var gotAll []netip.Prefix
+	for p := range tbl.All2() {
+		gotAll = append(gotAll, p)
+	}
+	if !slices.Equal(gotAll, wantAll) {
+		t.Fatalf("All2(), got %d prefixes, want: %d", len(gotAll), len(wantAll))
+	}
+}
@@ -0,0 +1,202 @@
+package extnetip
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Well-known prefixes of the IPv6 transition mechanisms.
+var (
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16") // RFC 3056
+	teredoPrefix    = netip.MustParsePrefix("2001::/32") // RFC 4380
+)
+
+// isatapID is the ISATAP interface identifier before the IPv4 address,
+// the u/l bit 0x02000000 is set for globally unique IPv4 addresses, RFC 5214.
+const (
+	isatapID     = 0x00005efe
+	isatapIDMask = 0xfdffffff
+)
+
+// covers6 reports whether the valid IPv6 prefix pfx covers
+// the IPv6 address u, by masking the uint128 values as in [Range].
+func covers6(pfx netip.Prefix, u uint128) bool {
+	mask := mask6(pfx.Bits())
+	return u.and(mask) == unwrap(pfx.Addr()).ip.and(mask)
+}
+
+// unwrap6 returns the uint128 value of ip, ok is false
+// if ip is not an IPv6 address or an IPv4-mapped IPv6 address.
+func unwrap6(ip netip.Addr) (u uint128, ok bool) {
+	if !ip.Is6() || ip.Is4In6() {
+		return
+	}
+	return unwrap(ip).ip, true
+}
+
+// Decode6to4 returns the IPv4 address embedded in the 6to4 address ip
+// in 2002::/16, the IPv4 address is in the bits 16 to 47, RFC 3056.
+//
+// It returns ok=false if ip is not a 6to4 address.
+func Decode6to4(ip netip.Addr) (v4 netip.Addr, ok bool) {
+	u, ok := unwrap6(ip)
+	if !ok || !covers6(sixToFourPrefix, u) {
+		return netip.Addr{}, false
+	}
+	return uint32ToAddr4(uint32(u.field(16, 32))), true
+}
+
+// Teredo is the decoded Teredo address, RFC 4380.
+type Teredo struct {
+	// Server is the IPv4 address of the Teredo server.
+	Server netip.Addr
+
+	// Flags are the Teredo flags, e.g. the cone bit 0x8000.
+	Flags uint16
+
+	// Client is the external IPv4 address and UDP port of the client,
+	// the obfuscation of the address and port is removed.
+	Client netip.AddrPort
+}
+
+// DecodeTeredo decodes the Teredo address ip in 2001::/32.
+//
+// The server IPv4 address is in the bits 32 to 63, followed by 16 bits
+// flags, the obfuscated client port and the obfuscated client IPv4 address,
+// obfuscated by inverting all bits.
+//
+// It returns ok=false if ip is not a Teredo address.
+func DecodeTeredo(ip netip.Addr) (t Teredo, ok bool) {
+	u, ok := unwrap6(ip)
+	if !ok || !covers6(teredoPrefix, u) {
+		return Teredo{}, false
+	}
+
+	port := ^uint16(u.field(80, 16))
+	client := ^uint32(u.field(96, 32))
+
+	return Teredo{
+		Server: uint32ToAddr4(uint32(u.field(32, 32))),
+		Flags:  uint16(u.field(64, 16)),
+		Client: netip.AddrPortFrom(uint32ToAddr4(client), port),
+	}, true
+}
+
+// DecodeISATAP returns the IPv4 address embedded in the ISATAP interface
+// identifier of ip, the interface identifier is 0:5efe:a.b.c.d or
+// 200:5efe:a.b.c.d for a globally unique IPv4 address, RFC 5214.
+//
+// The interface identifier is independent of the IPv6 prefix,
+// it returns ok=false if ip has no ISATAP interface identifier.
+func DecodeISATAP(ip netip.Addr) (v4 netip.Addr, ok bool) {
+	u, ok := unwrap6(ip)
+	if !ok || u.field(64, 32)&isatapIDMask != isatapID {
+		return netip.Addr{}, false
+	}
+	return uint32ToAddr4(uint32(u.field(96, 32))), true
+}
+
+// checkSixRD returns the number of embedded IPv4 bits for the 6rd domain
+// with the 6rd prefix rdPrefix and the IPv4MaskLen ipv4MaskLen.
+//
+// It returns an error wrapping [ErrInvalidPrefix] for an invalid 6rd prefix,
+// IPv4MaskLen or if the delegated prefix length exceeds 128 bits.
+func checkSixRD(rdPrefix netip.Prefix, ipv4MaskLen int) (n int, err error) {
+	if !rdPrefix.IsValid() || !rdPrefix.Addr().Is6() {
+		return 0, fmt.Errorf("%w: 6rd prefix %s", ErrInvalidPrefix, rdPrefix)
+	}
+	if ipv4MaskLen < 0 || ipv4MaskLen > 32 {
+		return 0, fmt.Errorf("%w: 6rd IPv4MaskLen %d", ErrInvalidPrefix, ipv4MaskLen)
+	}
+
+	n = 32 - ipv4MaskLen
+	if rdPrefix.Bits()+n > 128 {
+		return 0, fmt.Errorf("%w: 6rd prefix %s with IPv4MaskLen %d exceeds 128 bits", ErrInvalidPrefix, rdPrefix, ipv4MaskLen)
+	}
+	return n, nil
+}
+
+// DecodeSixRD returns the CE IPv4 address embedded in the 6rd address ip,
+// for the 6rd domain with the 6rd prefix rdPrefix, RFC 5969.
+//
+// The IPv4 prefix ipv4Common holds the high-order bits common to all CE
+// IPv4 addresses of the domain, its length is the 6rd IPv4MaskLen, e.g.
+// 0.0.0.0/0 for an IPv4MaskLen of 0. The remaining 32 - IPv4MaskLen bits
+// of the CE IPv4 address follow the 6rd prefix in ip.
+//
+// It returns ok=false for an invalid 6rd configuration, if the delegated
+// prefix would exceed 128 bits or if ip is not within rdPrefix.
+func DecodeSixRD(rdPrefix, ipv4Common netip.Prefix, ip netip.Addr) (v4 netip.Addr, ok bool) {
+	if !ipv4Common.IsValid() || !ipv4Common.Addr().Is4() {
+		return netip.Addr{}, false
+	}
+
+	n, err := checkSixRD(rdPrefix, ipv4Common.Bits())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	u, ok := unwrap6(ip)
+	if !ok || !covers6(rdPrefix, u) {
+		return netip.Addr{}, false
+	}
+
+	common := addr4ToUint32(ipv4Common.Masked().Addr())
+	return uint32ToAddr4(common | uint32(u.field(rdPrefix.Bits(), n))), true
+}
+
+// SixRDPrefix returns the 6rd delegated prefix of the CE with the IPv4
+// address ce, for the 6rd domain with the 6rd prefix rdPrefix, RFC 5969.
+//
+// The delegated prefix is the 6rd prefix followed by the low-order
+// 32 - ipv4MaskLen bits of ce, e.g. 2001:db8::/32 with an IPv4MaskLen
+// of 8 and the CE 10.1.2.3 is 2001:db8:102:300::/56.
+//
+// It returns an error wrapping [ErrInvalidPrefix] for an invalid 6rd prefix
+// or IPv4MaskLen, or if the delegated prefix length exceeds 128 bits, and
+// [ErrInvalidAddr] if ce is not an IPv4 address.
+func SixRDPrefix(rdPrefix netip.Prefix, ipv4MaskLen int, ce netip.Addr) (netip.Prefix, error) {
+	n, err := checkSixRD(rdPrefix, ipv4MaskLen)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if !ce.Is4() {
+		return netip.Prefix{}, fmt.Errorf("%w: CE %s is not IPv4", ErrInvalidAddr, ce)
+	}
+
+	u := unwrap(rdPrefix.Masked().Addr()).ip
+	u = u.setField(rdPrefix.Bits(), n, uint64(addr4ToUint32(ce)))
+
+	return netip.PrefixFrom(wrap(fromUint128(u, false)), rdPrefix.Bits()+n), nil
+}
+
+// SixRDCE returns the CE IPv4 address for the 6rd delegated prefix
+// delegated, the reverse of [SixRDPrefix].
+//
+// The IPv4 prefix ipv4Common holds the high-order bits common to all
+// CE IPv4 addresses of the domain, its length is the IPv4MaskLen, see
+// [DecodeSixRD]. The delegated prefix may also be a subnet of the
+// delegated prefix, e.g. a /64 of a /56.
+//
+// It returns an error wrapping [ErrInvalidPrefix] for an invalid 6rd
+// configuration or if delegated is not a delegated prefix of the domain.
+func SixRDCE(rdPrefix, ipv4Common, delegated netip.Prefix) (netip.Addr, error) {
+	if !ipv4Common.IsValid() || !ipv4Common.Addr().Is4() {
+		return netip.Addr{}, fmt.Errorf("%w: 6rd IPv4 prefix %s", ErrInvalidPrefix, ipv4Common)
+	}
+
+	n, err := checkSixRD(rdPrefix, ipv4Common.Bits())
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	if !delegated.IsValid() || delegated.Bits() < rdPrefix.Bits()+n {
+		return netip.Addr{}, fmt.Errorf("%w: %s is no delegated prefix of %s", ErrInvalidPrefix, delegated, rdPrefix)
+	}
+
+	v4, ok := DecodeSixRD(rdPrefix, ipv4Common, delegated.Addr())
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("%w: %s is not in %s", ErrInvalidPrefix, delegated, rdPrefix)
+	}
+	return v4, nil
+}
@@ -0,0 +1,184 @@
+package extnetip_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/extnetip"
+)
+
+func TestDecode6to4(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ip   netip.Addr
+		want netip.Addr
+		ok   bool
+	}{
+		{mpa("2002:c000:204::1"), mpa("192.0.2.4"), true},
+		{mpa("2002:ffff:ffff:ffff::"), mpa("255.255.255.255"), true},
+		{mpa("2003:c000:204::1"), netip.Addr{}, false},
+		{mpa("192.0.2.4"), netip.Addr{}, false},
+		{netip.Addr{}, netip.Addr{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := extnetip.Decode6to4(tt.ip)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Decode6to4(%s), got: %s, %v, want: %s, %v", tt.ip, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDecodeTeredo(t *testing.T) {
+	t.Parallel()
+
+	// RFC 4380, section 4
+	got, ok := extnetip.DecodeTeredo(mpa("2001:0:4136:e378:8000:63bf:3fff:fdd2"))
+	want := extnetip.Teredo{
+		Server: mpa("65.54.227.120"),
+		Flags:  0x8000,
+		Client: netip.MustParseAddrPort("192.0.2.45:40000"),
+	}
+	if !ok || got != want {
+		t.Errorf("DecodeTeredo, got: %+v, %v, want: %+v", got, ok, want)
+	}
+
+	for _, ip := range []netip.Addr{mpa("2001:1::1"), mpa("2002::1"), mpa("65.54.227.120"), {}} {
+		if got, ok := extnetip.DecodeTeredo(ip); ok {
+			t.Errorf("DecodeTeredo(%s), got: %+v, want: not ok", ip, got)
+		}
+	}
+}
+
+func TestDecodeISATAP(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ip   netip.Addr
+		want netip.Addr
+		ok   bool
+	}{
+		{mpa("fe80::5efe:192.0.2.143"), mpa("192.0.2.143"), true},
+		{mpa("2001:db8::200:5efe:c000:28f"), mpa("192.0.2.143"), true},
+		{mpa("fe80::100:5efe:c000:28f"), netip.Addr{}, false},
+		{mpa("fe80::5eff:c000:28f"), netip.Addr{}, false},
+		{mpa("192.0.2.143"), netip.Addr{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := extnetip.DecodeISATAP(tt.ip)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("DecodeISATAP(%s), got: %s, %v, want: %s, %v", tt.ip, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDecodeSixRD(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		rdPrefix netip.Prefix
+		common   netip.Prefix
+		ip       netip.Addr
+		want     netip.Addr
+		ok       bool
+	}{
+		{mpp("2001:db8::/32"), mpp("0.0.0.0/0"), mpa("2001:db8:c000:201::1"), mpa("192.0.2.1"), true},
+		{mpp("2001:db8:100::/40"), mpp("10.0.0.0/8"), mpa("2001:db8:101:203::1"), mpa("10.1.2.3"), true},
+		{mpp("2001:db8:100::/40"), mpp("10.9.9.9/8"), mpa("2001:db8:101:203::1"), mpa("10.1.2.3"), true},
+		{mpp("2001:db8::/60"), mpp("192.168.0.0/16"), mpa("2001:db8:0:a:bcd0::"), mpa("192.168.171.205"), true},
+		{mpp("2001:db8:100::/40"), mpp("10.0.0.0/8"), mpa("2001:db8:201:203::1"), netip.Addr{}, false}, // not in 6rd prefix
+		{mpp("2001:db8::/112"), mpp("0.0.0.0/0"), mpa("2001:db8::1"), netip.Addr{}, false},             // exceeds 128 bits
+		{mpp("10.0.0.0/8"), mpp("0.0.0.0/0"), mpa("2001:db8::1"), netip.Addr{}, false},                 // invalid 6rd prefix
+		{mpp("2001:db8::/32"), mpp("2001:db8::/32"), mpa("2001:db8::1"), netip.Addr{}, false},          // invalid IPv4 prefix
+	}
+
+	for _, tt := range tests {
+		got, ok := extnetip.DecodeSixRD(tt.rdPrefix, tt.common, tt.ip)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("DecodeSixRD(%s, %s, %s), got: %s, %v, want: %s, %v", tt.rdPrefix, tt.common, tt.ip, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSixRDPrefix(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		rdPrefix    netip.Prefix
+		ipv4MaskLen int
+		ce          netip.Addr
+		want        netip.Prefix
+	}{
+		{mpp("2001:db8::/32"), 0, mpa("192.0.2.1"), mpp("2001:db8:c000:201::/64")},
+		{mpp("2001:db8::/32"), 8, mpa("10.1.2.3"), mpp("2001:db8:102:300::/56")},
+		{mpp("2001:db8:100::/40"), 16, mpa("192.168.171.205"), mpp("2001:db8:1ab:cd00::/56")},
+		{mpp("2001:db8::/60"), 16, mpa("192.168.171.205"), mpp("2001:db8:0:a:bcd0::/76")},
+		{mpp("2001:db8::/96"), 0, mpa("192.0.2.1"), mpp("2001:db8::c000:201/128")},
+		{mpp("2001:db8::/128"), 32, mpa("192.0.2.1"), mpp("2001:db8::/128")},
+		{mpp("2001:db8:ffff::/32"), 0, mpa("192.0.2.1"), mpp("2001:db8:c000:201::/64")}, // non-canonical 6rd prefix
+	}
+
+	for _, tt := range tests {
+		got, err := extnetip.SixRDPrefix(tt.rdPrefix, tt.ipv4MaskLen, tt.ce)
+		if err != nil {
+			t.Fatalf("SixRDPrefix(%s, %d, %s), unexpected error: %v", tt.rdPrefix, tt.ipv4MaskLen, tt.ce, err)
+		}
+		if got != tt.want {
+			t.Errorf("SixRDPrefix(%s, %d, %s), got: %s, want: %s", tt.rdPrefix, tt.ipv4MaskLen, tt.ce, got, tt.want)
+		}
+
+		common := netip.PrefixFrom(tt.ce, tt.ipv4MaskLen).Masked()
+		back, err := extnetip.SixRDCE(tt.rdPrefix, common, got)
+		if err != nil {
+			t.Fatalf("SixRDCE(%s, %s, %s), unexpected error: %v", tt.rdPrefix, common, got, err)
+		}
+		if back != tt.ce {
+			t.Errorf("SixRDCE(%s, %s, %s), got: %s, want: %s", tt.rdPrefix, common, got, back, tt.ce)
+		}
+	}
+}
+
+func TestSixRDPrefixErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		rdPrefix    netip.Prefix
+		ipv4MaskLen int
+		ce          netip.Addr
+		want        error
+	}{
+		{netip.Prefix{}, 0, mpa("192.0.2.1"), extnetip.ErrInvalidPrefix},
+		{mpp("10.0.0.0/8"), 0, mpa("192.0.2.1"), extnetip.ErrInvalidPrefix},
+		{mpp("2001:db8::/32"), -1, mpa("192.0.2.1"), extnetip.ErrInvalidPrefix},
+		{mpp("2001:db8::/32"), 33, mpa("192.0.2.1"), extnetip.ErrInvalidPrefix},
+		{mpp("2001:db8::/97"), 0, mpa("192.0.2.1"), extnetip.ErrInvalidPrefix}, // exceeds 128 bits
+		{mpp("2001:db8::/32"), 0, mpa("2001:db8::1"), extnetip.ErrInvalidAddr},
+		{mpp("2001:db8::/32"), 0, netip.Addr{}, extnetip.ErrInvalidAddr},
+	}
+
+	for _, tt := range tests {
+		if _, err := extnetip.SixRDPrefix(tt.rdPrefix, tt.ipv4MaskLen, tt.ce); !errors.Is(err, tt.want) {
+			t.Errorf("SixRDPrefix(%s, %d, %s), got: %v, want: %v", tt.rdPrefix, tt.ipv4MaskLen, tt.ce, err, tt.want)
+		}
+	}
+}
+
+func TestSixRDCE(t *testing.T) {
+	t.Parallel()
+	rdPrefix := mpp("2001:db8::/32")
+	common := mpp("10.0.0.0/8")
+
+	// a subnet of the delegated prefix
+	got, err := extnetip.SixRDCE(rdPrefix, common, mpp("2001:db8:102:3ff::/64"))
+	if err != nil || got != mpa("10.1.2.3") {
+		t.Errorf("SixRDCE subnet, got: %s, %v, want: %s", got, err, mpa("10.1.2.3"))
+	}
+
+	for _, delegated := range []netip.Prefix{{}, mpp("2001:db8:102::/48"), mpp("2001:db9:102:300::/56")} {
+		if _, err := extnetip.SixRDCE(rdPrefix, common, delegated); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+			t.Errorf("SixRDCE(%s), got: %v, want: %v", delegated, err, extnetip.ErrInvalidPrefix)
+		}
+	}
+
+	if _, err := extnetip.SixRDCE(rdPrefix, mpp("2001:db8::/8"), mpp("2001:db8:102:300::/56")); !errors.Is(err, extnetip.ErrInvalidPrefix) {
+		t.Errorf("SixRDCE IPv6 common prefix, got: %v, want: %v", err, extnetip.ErrInvalidPrefix)
+	}
+}
@@ -116,3 +116,57 @@ func (u uint128) compare(v uint128) int {
 	// u.hi == v.hi && u.lo == v.lo
 	return 0
 }
+
+// addOne returns u + 1, wrapping around on overflow.
+func (u uint128) addOne() uint128 {
+	lo, carry := bits.Add64(u.lo, 1, 0)
+	return uint128{u.hi + carry, lo}
+}
+
+// subOne returns u - 1, wrapping around on underflow.
+func (u uint128) subOne() uint128 {
+	lo, borrow := bits.Sub64(u.lo, 1, 0)
+	return uint128{u.hi - borrow, lo}
+}
+
+// sub returns u - v, wrapping around on underflow.
+func (u uint128) sub(v uint128) uint128 {
+	lo, borrow := bits.Sub64(u.lo, v.lo, 0)
+	return uint128{u.hi - v.hi - borrow, lo}
+}
+
+// lsh returns u shifted left by n bits, 0 <= n <= 128.
+func (u uint128) lsh(n int) uint128 {
+	if n >= 64 {
+		return uint128{u.lo << (n - 64), 0}
+	}
+	return uint128{u.hi<<n | u.lo>>(64-n), u.lo << n}
+}
+
+// add returns u + v and the carry out of the most significant bit.
+func (u uint128) add(v uint128) (sum uint128, carry uint64) {
+	lo, c := bits.Add64(u.lo, v.lo, 0)
+	hi, carry := bits.Add64(u.hi, v.hi, c)
+	return uint128{hi, lo}, carry
+}
+
+// rsh returns u shifted right by n bits, 0 <= n <= 128.
+func (u uint128) rsh(n int) uint128 {
+	if n >= 64 {
+		return uint128{0, u.hi >> (n - 64)}
+	}
+	return uint128{u.hi >> n, u.lo>>n | u.hi<<(64-n)}
+}
+
+// field returns the n bits of u at the bit offset off, counted from
+// the most significant bit, 0 <= n <= 64 and off+n <= 128.
+func (u uint128) field(off, n int) uint64 {
+	return u.rsh(128-off-n).lo & (^uint64(0) >> (64 - n))
+}
+
+// setField returns u with the n bits at the bit offset off replaced
+// by the low n bits of v, 0 <= n <= 64 and off+n <= 128.
+func (u uint128) setField(off, n int, v uint64) uint128 {
+	m := uint128{0, ^uint64(0) >> (64 - n)}.lsh(128 - off - n)
+	return u.and(m.not()).or(uint128{0, v}.lsh(128 - off - n).and(m))
+}